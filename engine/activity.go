@@ -0,0 +1,73 @@
+package engine
+
+// activityBuffer is the number of states a subscriber may lag behind before being dropped.
+const activityBuffer = 1e3
+
+// Subscribe returns a channel receiving the grid state after each change of the game:
+// Empty while the game runs, then the final state. Every subscriber receives every
+// change. A subscriber lagging more than activityBuffer changes behind is dropped
+// and its channel closed, so a closed channel before the final state means changes
+// were missed. The channel is also closed once the game is finished or released,
+// right away when it already is.
+// Subscribers must call Unsubscribe when they stop listening.
+func (f *Four) Subscribe() chan State {
+	ch := make(chan State, activityBuffer)
+	f.Lock()
+	defer f.Unlock()
+	if f.GridState != Empty || f.released {
+		close(ch)
+		return ch
+	}
+	if f.subs == nil {
+		f.subs = map[chan State]bool{}
+	}
+	f.subs[ch] = true
+	return ch
+}
+
+// Unsubscribe removes the subscriber and closes its channel, if not already closed.
+func (f *Four) Unsubscribe(ch chan State) {
+	f.Lock()
+	defer f.Unlock()
+	if f.subs[ch] {
+		delete(f.subs, ch)
+		close(ch)
+	}
+}
+
+// Release closes the channels of all the subscribers, as when the game is finished,
+// and stops reporting changes. Used when the game ends outside of the grid, on resign
+// for instance.
+func (f *Four) Release() {
+	f.Lock()
+	defer f.Unlock()
+	f.released = true
+	f.release()
+}
+
+// release closes the channels of all the subscribers. The lock must be held.
+func (f *Four) release() {
+	for ch := range f.subs {
+		close(ch)
+	}
+	f.subs = nil
+}
+
+// notify sends the given state to the subscribers.
+// If the state is not Empty, the game is finished and the channels are closed.
+func (f *Four) notify(s State) {
+	f.Lock()
+	defer f.Unlock()
+
+	for ch := range f.subs {
+		select {
+		case ch <- s:
+		default:
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}
+	if s != Empty {
+		f.release()
+	}
+}
@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+// drain returns the states pending on the channel and whether it is closed.
+func drain(ch chan State) ([]State, bool) {
+	var states []State
+	for {
+		select {
+		case s, ok := <-ch:
+			if !ok {
+				return states, true
+			}
+			states = append(states, s)
+		default:
+			return states, false
+		}
+	}
+}
+
+// TestSubscribe checks every subscriber receives every change until it
+// unsubscribes or the game ends.
+func TestSubscribe(t *testing.T) {
+	f, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs := []chan State{f.Subscribe(), f.Subscribe(), f.Subscribe()}
+	expect := func(i int, states []State, closed bool) {
+		got, gotClosed := drain(subs[i])
+		if !reflect.DeepEqual(got, states) || gotClosed != closed {
+			t.Fatalf("Unexpected changes sent to subscriber %d.\nExpected:\t%v, closed: %t\nGot:\t\t%v, closed: %t", i, states, closed, got, gotClosed)
+		}
+	}
+
+	if _, _, err := f.PlayerMove(Red, 0); err != nil {
+		t.Fatal(err)
+	}
+	f.Unsubscribe(subs[1])
+	f.Unsubscribe(subs[1]) // Unsubscribing twice is harmless.
+	if _, err := f.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	expect(0, []State{Empty, Empty}, false)
+	expect(1, []State{Empty}, true)
+
+	// Red wins on column 0.
+	for _, col := range []int{0, 1, 0, 1, 0, 1, 0} {
+		if _, _, err := f.PlayerMove(f.CurPlayer, col); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect(0, []State{Empty, Empty, Empty, Empty, Empty, Empty, Red}, true)
+	expect(2, []State{Empty, Empty, Empty, Empty, Empty, Empty, Empty, Empty, Red}, true)
+	f.Unsubscribe(subs[0]) // Already closed by the end of the game.
+
+	// Subscribing to a finished game closes the channel right away.
+	if states, closed := drain(f.Subscribe()); len(states) != 0 || !closed {
+		t.Fatalf("Unexpected subscription to a finished game: %v, closed: %t", states, closed)
+	}
+}
+
+// TestSubscribeRelease checks the release and the slow subscribers close the channels.
+func TestSubscribeRelease(t *testing.T) {
+	f, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow, fast := f.Subscribe(), f.Subscribe()
+	for i := 0; i <= activityBuffer; i++ {
+		if i%2 == 0 {
+			if _, _, err := f.PlayerMove(Red, 0); err != nil {
+				t.Fatal(err)
+			}
+		} else if _, err := f.Undo(); err != nil {
+			t.Fatal(err)
+		}
+		if _, closed := drain(fast); closed {
+			t.Fatalf("Unexpected drop of the subscriber keeping up after %d changes", i+1)
+		}
+	}
+	if states, closed := drain(slow); len(states) != activityBuffer || !closed {
+		t.Fatalf("Unexpected changes sent to the slow subscriber.\nExpected:\t%d, closed: true\nGot:\t\t%d, closed: %t", int(activityBuffer), len(states), closed)
+	}
+
+	f.Release()
+	if states, closed := drain(fast); len(states) != 0 || !closed {
+		t.Fatalf("Unexpected changes sent after the release: %v, closed: %t", states, closed)
+	}
+	if states, closed := drain(f.Subscribe()); len(states) != 0 || !closed {
+		t.Fatalf("Unexpected subscription to a released game: %v, closed: %t", states, closed)
+	}
+}
@@ -0,0 +1,57 @@
+// Package ai provides computer players for connect four.
+package ai
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/creack/gofour/engine"
+	"github.com/pkg/errors"
+)
+
+// Common errors.
+var (
+	ErrGameOver = errors.New("game is finished")
+	ErrNoBudget = errors.New("no iteration nor time budget")
+)
+
+// Bot is a computer player.
+type Bot interface {
+	// Move returns the move to play for the current player.
+	Move(*engine.Four) (engine.Move, error)
+}
+
+// Levels holds the available difficulty levels.
+var Levels = map[string]Bot{
+	"easy":   &Player{Depth: 2},
+	"medium": &Player{Depth: 5, Budget: time.Second},
+	"hard":   &Player{Depth: 20, Budget: 3 * time.Second},
+	"mcts":   &MCTS{Budget: 3 * time.Second, Workers: runtime.NumCPU()},
+}
+
+// ParseSeats parses a comma separated list of <player>:<level> seats, e.g. "2:hard,3:easy".
+// Players are 1 indexed, as displayed by the runtimes.
+func ParseSeats(s string, nPlayers int) (map[engine.State]Bot, error) {
+	seats := map[engine.State]Bot{}
+	if s == "" {
+		return seats, nil
+	}
+	for _, seat := range strings.Split(s, ",") {
+		parts := strings.SplitN(seat, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid ai seat '%s', expected <player>:<level>", seat)
+		}
+		n, err := strconv.Atoi(parts[0])
+		if err != nil || n < 1 || n > nPlayers {
+			return nil, errors.Errorf("invalid ai player '%s', expected 1 to %d", parts[0], nPlayers)
+		}
+		bot, ok := Levels[parts[1]]
+		if !ok {
+			return nil, errors.Errorf("unknown ai level '%s'", parts[1])
+		}
+		seats[engine.AvailablePlayers[n-1]] = bot
+	}
+	return seats, nil
+}
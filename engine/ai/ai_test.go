@@ -0,0 +1,181 @@
+package ai
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/creack/gofour/engine"
+)
+
+// quick returns a copy of the level with its time budget capped.
+func quick(t *testing.T, bot Bot, budget time.Duration) Bot {
+	switch b := bot.(type) {
+	case *Player:
+		p := *b
+		if p.Budget > budget {
+			p.Budget = budget
+		}
+		return &p
+	case *MCTS:
+		m := *b
+		m.Budget = budget
+		return &m
+	}
+	t.Fatalf("Unexpected bot %T", bot)
+	return nil
+}
+
+// position returns a default grid after the given columns are played, in turn.
+func position(t *testing.T, nPlayers int, cols ...int) *engine.Four {
+	f, err := engine.NewConnectFour(engine.DefaultCols, engine.DefaultRows, nPlayers, engine.DefaultNWin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range cols {
+		if _, _, err := f.PlayerMove(f.CurPlayer, col); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f.GridState != engine.Empty {
+		t.Fatalf("Unexpected end of the game after %v", cols)
+	}
+	return f
+}
+
+// TestForcedMoves checks every level takes an immediate win and blocks the
+// immediate win of the next player.
+func TestForcedMoves(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		nPlayers int
+		cols     []int
+		expect   int
+	}{
+		{name: "win", nPlayers: 2, cols: []int{0, 1, 0, 1, 0, 6}, expect: 0},
+		{name: "win with 3 players", nPlayers: 3, cols: []int{0, 1, 2, 0, 1, 2, 0, 6, 5}, expect: 0},
+		{name: "win over a block", nPlayers: 2, cols: []int{0, 6, 0, 6, 0, 6}, expect: 0},
+		{name: "block", nPlayers: 2, cols: []int{0, 6, 0, 6, 1, 6}, expect: 6},
+		{name: "block with 3 players", nPlayers: 3, cols: []int{0, 6, 1, 0, 6, 1, 2, 6, 3}, expect: 6},
+	} {
+		for level, bot := range Levels {
+			f := position(t, tc.nPlayers, tc.cols...)
+			before := f.Hash()
+			m, err := quick(t, bot, 500*time.Millisecond).Move(f)
+			if err != nil {
+				t.Fatalf("[%s/%s] %s", tc.name, level, err)
+			}
+			if m.Column != tc.expect || m.Player != f.CurPlayer {
+				t.Fatalf("[%s/%s] Unexpected move.\nExpected:\t%s plays %d\nGot:\t\t%s plays %d", tc.name, level, f.CurPlayer, tc.expect, m.Player, m.Column)
+			}
+			if f.Hash() != before || len(f.History) != len(tc.cols) {
+				t.Fatalf("[%s/%s] Unexpected change of the game while searching", tc.name, level)
+			}
+		}
+	}
+}
+
+// TestLegalMoves plays every level from random positions of every variant.
+func TestLegalMoves(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for variant := range engine.Variants {
+		for level, bot := range Levels {
+			bot := quick(t, bot, 20*time.Millisecond)
+			for n := 0; n < 5; n++ {
+				f, err := engine.NewConnectFour(4+rnd.Intn(5), 4+rnd.Intn(4), 2+rnd.Intn(2), engine.DefaultNWin, engine.WithVariant(variant))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := rnd.Intn(f.Columns * f.Rows); i > 0 && f.GridState == engine.Empty; i-- {
+					moves := f.Rules.LegalMoves(f)
+					if len(moves) == 0 {
+						break
+					}
+					if _, _, err := f.Play(moves[rnd.Intn(len(moves))]); err != nil {
+						t.Fatal(err)
+					}
+				}
+				m, err := bot.Move(f)
+				if f.GridState != engine.Empty {
+					if err != ErrGameOver {
+						t.Fatalf("[%s/%s] Unexpected error on a finished game.\nExpected:\t%v\nGot:\t\t%v", variant, level, ErrGameOver, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("[%s/%s] %s", variant, level, err)
+				}
+				if err := f.Validate(m); err != nil {
+					t.Fatalf("[%s/%s] Unexpected illegal move %+v in %s: %s", variant, level, m, f.Notation(), err)
+				}
+			}
+		}
+	}
+}
+
+// TestBudget checks the levels stop searching on time, and MCTS after its iterations.
+func TestBudget(t *testing.T) {
+	const budget = 100 * time.Millisecond
+	for level, bot := range Levels {
+		if p, ok := bot.(*Player); ok && p.Budget == 0 {
+			continue // Bound by its depth only.
+		}
+		f := position(t, engine.DefaultNPlayers)
+		start := time.Now()
+		if _, err := quick(t, bot, budget).Move(f); err != nil {
+			t.Fatalf("[%s] %s", level, err)
+		}
+		if elapsed := time.Since(start); elapsed > 3*budget {
+			t.Fatalf("[%s] Unexpected search time.\nExpected:\t%s at most\nGot:\t\t%s", level, 3*budget, elapsed)
+		}
+	}
+
+	m := &MCTS{Iterations: 100}
+	if root := m.search(position(t, engine.DefaultNPlayers), rand.New(rand.NewSource(1)), m.Iterations, time.Time{}); root.visits != 100 {
+		t.Fatalf("Unexpected playouts.\nExpected:\t%d\nGot:\t\t%v", m.Iterations, root.visits)
+	}
+	if _, err := (&MCTS{}).Move(position(t, engine.DefaultNPlayers)); err != ErrNoBudget {
+		t.Fatalf("Unexpected error without budget.\nExpected:\t%v\nGot:\t\t%v", ErrNoBudget, err)
+	}
+}
+
+// TestMCTSSeed checks the search is reproducible with a given seed and an iteration budget.
+func TestMCTSSeed(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for n := 0; n < 20; n++ {
+		f := position(t, 2+rnd.Intn(2))
+		for i := rnd.Intn(20); i > 0 && f.GridState == engine.Empty; i-- {
+			moves := f.Rules.LegalMoves(f)
+			if _, _, err := f.Play(moves[rnd.Intn(len(moves))]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if f.GridState != engine.Empty {
+			continue
+		}
+		visits := func() []float64 {
+			m := &MCTS{Iterations: 300, Seed: 42}
+			root := m.search(f.Clone(), rand.New(rand.NewSource(m.Seed)), m.Iterations, time.Time{})
+			ret := []float64{}
+			for _, child := range root.children {
+				ret = append(ret, float64(child.move.Column), child.visits, child.reward)
+			}
+			return ret
+		}
+		if a, b := visits(), visits(); !reflect.DeepEqual(a, b) {
+			t.Fatalf("Unexpected search statistics with the same seed in %s.\nExpected:\t%v\nGot:\t\t%v", f.Notation(), a, b)
+		}
+
+		m := &MCTS{Iterations: 300, Workers: 3, Seed: 42}
+		first, err := m.Move(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			if again, err := m.Move(f); err != nil || again != first {
+				t.Fatalf("Unexpected move with the same seed in %s.\nExpected:\t%+v\nGot:\t\t%+v (%v)", f.Notation(), first, again, err)
+			}
+		}
+	}
+}
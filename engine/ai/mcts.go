@@ -0,0 +1,189 @@
+package ai
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/creack/gofour/engine"
+)
+
+// MCTS is a computer player running a Monte Carlo tree search with UCT.
+//
+// Unlike Player, it does not need a heuristic and supports any number of
+// players and any variant: each node tracks the score, as given by the rules,
+// of the player who moved into it.
+// Each worker grows its own tree on its own copy of the game, the root
+// statistics are merged at the end.
+type MCTS struct {
+	Iterations  int           // Total playouts per move, 0 for no limit.
+	Budget      time.Duration // Time budget per move, 0 for none.
+	Workers     int           // Parallel searches, defaults to 1.
+	Exploration float64       // UCT exploration constant, defaults to sqrt(2).
+	Seed        int64         // Seed of the playouts, worker i using Seed+i. 0 for a time based one.
+}
+
+// node is a node of the search tree.
+type node struct {
+	parent   *node
+	move     engine.Move // Move played to reach the node.
+	children []*node
+	untried  []engine.Move // Legal moves not expanded yet.
+
+	visits float64
+	reward float64 // Sum of the scores of the player who played move.
+}
+
+// moveKey identifies a move regardless of its outcome.
+type moveKey struct {
+	col, row int
+	pop      bool
+}
+
+// keyOf returns the key of the given move.
+func keyOf(m engine.Move) moveKey {
+	return moveKey{col: m.Column, row: m.Row, pop: m.Pop}
+}
+
+// legalMoves returns the moves the current player can play.
+func legalMoves(f *engine.Four) []engine.Move {
+	if f.GridState != engine.Empty {
+		return nil
+	}
+	return f.Rules.LegalMoves(f)
+}
+
+// Move returns the move to play for the current player.
+func (m *MCTS) Move(f *engine.Four) (engine.Move, error) {
+	if f.GridState != engine.Empty {
+		return engine.Move{}, ErrGameOver
+	}
+	if m.Iterations <= 0 && m.Budget <= 0 {
+		return engine.Move{}, ErrNoBudget
+	}
+	if len(legalMoves(f)) == 0 {
+		return engine.Move{}, engine.ErrNoMove
+	}
+	workers := m.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	var deadline time.Time
+	if m.Budget > 0 {
+		deadline = time.Now().Add(m.Budget)
+	}
+
+	// Each worker gets its own copy of the game and its share of the iterations.
+	roots := make([]*node, workers)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		g := f.Clone()
+		iterations := 0
+		if m.Iterations > 0 {
+			iterations = (m.Iterations + workers - 1) / workers
+		}
+		wg.Add(1)
+		go func(i int, g *engine.Four) {
+			defer wg.Done()
+			seed := m.Seed
+			if seed == 0 {
+				seed = time.Now().UnixNano()
+			}
+			rnd := rand.New(rand.NewSource(seed + int64(i)))
+			roots[i] = m.search(g, rnd, iterations, deadline)
+		}(i, g)
+	}
+	wg.Wait()
+
+	// Merge the root statistics and pick the most visited move.
+	var (
+		moves  = legalMoves(f)
+		visits = map[moveKey]float64{}
+	)
+	for _, root := range roots {
+		for _, child := range root.children {
+			visits[keyOf(child.move)] += child.visits
+		}
+	}
+	best := moves[0] // Not even a single iteration, play the first legal move.
+	for _, move := range moves {
+		if visits[keyOf(move)] > visits[keyOf(best)] {
+			best = move
+		}
+	}
+	return best, nil
+}
+
+// search grows a tree from the current position of g until the budget is exhausted.
+// Always runs at least one iteration.
+func (m *MCTS) search(g *engine.Four, rnd *rand.Rand, iterations int, deadline time.Time) *node {
+	c := m.Exploration
+	if c == 0 {
+		c = math.Sqrt2
+	}
+	root := &node{untried: legalMoves(g)}
+	for i := 0; (iterations <= 0 || i < iterations) && (deadline.IsZero() || i == 0 || time.Now().Before(deadline)); i++ {
+		played := 0
+
+		// Selection.
+		n := root
+		for len(n.untried) == 0 && len(n.children) != 0 {
+			n = n.selectChild(c)
+			_, _, _ = g.Play(n.move)
+			played++
+		}
+
+		// Expansion.
+		if len(n.untried) != 0 {
+			j := rnd.Intn(len(n.untried))
+			move := n.untried[j]
+			n.untried[j] = n.untried[len(n.untried)-1]
+			n.untried = n.untried[:len(n.untried)-1]
+
+			child := &node{parent: n, move: move}
+			_, _, _ = g.Play(move)
+			played++
+			child.untried = legalMoves(g)
+			n.children = append(n.children, child)
+			n = child
+		}
+
+		// Simulation.
+		for g.GridState == engine.Empty {
+			moves := legalMoves(g)
+			if len(moves) == 0 {
+				break
+			}
+			_, _, _ = g.Play(moves[rnd.Intn(len(moves))])
+			played++
+		}
+
+		// Backpropagation. The root has no move, hence no player.
+		for ; n.parent != nil; n = n.parent {
+			n.visits++
+			n.reward += g.Rules.Score(g, n.move.Player)
+		}
+		n.visits++
+
+		// Rewind to the root position.
+		for ; played > 0; played-- {
+			_, _ = g.Undo()
+		}
+	}
+	return root
+}
+
+// selectChild returns the child maximizing the UCT value.
+func (n *node) selectChild(c float64) *node {
+	var best *node
+	bestValue := math.Inf(-1)
+	logVisits := math.Log(n.visits)
+	for _, child := range n.children {
+		v := child.reward/child.visits + c*math.Sqrt(logVisits/child.visits)
+		if v > bestValue {
+			best, bestValue = child, v
+		}
+	}
+	return best
+}
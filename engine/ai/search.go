@@ -0,0 +1,328 @@
+package ai
+
+import (
+	"time"
+
+	"github.com/creack/gofour/engine"
+)
+
+// Scores bounds.
+const (
+	winScore  = 1 << 30 // Score of a won position, minus the distance to the win. Fits in 32 bits.
+	maxScore  = winScore + 1
+	maxWeight = 6            // Cap on the window weight exponent.
+	maxEval   = winScore / 4 // Cap on the heuristic score, keeps it far from winScore on any grid.
+	tableSize = 1 << 18
+)
+
+// Player is a computer player running a negamax search with alpha-beta pruning.
+//
+// With more than two players, the search is paranoid: all the opponents
+// are assumed to play together against the current player and their team.
+type Player struct {
+	Depth  int           // Maximum search depth, in plies.
+	Budget time.Duration // Time budget per move, 0 for none.
+}
+
+// search holds the state of a single search.
+type search struct {
+	f    *engine.Four
+	me   engine.State // Player we are searching for.
+	rank []int        // Rank of each column, center first.
+	tt   *engine.TranspositionTable
+
+	deadline  time.Time
+	abortable bool // Set once we have a move to fall back on.
+	aborted   bool // Set when the budget is exhausted.
+	nodes     int
+}
+
+// Move returns the move to play for the current player.
+// Uses iterative deepening until either the depth or the time budget is reached.
+func (p *Player) Move(f *engine.Four) (engine.Move, error) {
+	if f.GridState != engine.Empty {
+		return engine.Move{}, ErrGameOver
+	}
+	g := f.Clone()
+	s := &search{
+		f:    g,
+		me:   g.CurPlayer,
+		rank: make([]int, g.Columns),
+		tt:   engine.NewTranspositionTable(tableSize),
+	}
+	for i, col := range centerFirst(g.Columns) {
+		s.rank[col] = i
+	}
+	if p.Budget > 0 {
+		s.deadline = time.Now().Add(p.Budget)
+	}
+	moves := s.moves()
+	if len(moves) == 0 {
+		return engine.Move{}, engine.ErrNoMove
+	}
+
+	best := -1
+	for depth := 1; depth <= p.Depth || depth == 1; depth++ {
+		i, score := s.root(moves, depth, best)
+		if s.aborted {
+			break
+		}
+		best = i
+		s.abortable = true
+
+		// Stop as soon as the outcome is known.
+		if score > winScore/2 || score < -winScore/2 {
+			break
+		}
+	}
+	return moves[best], nil
+}
+
+// centerFirst returns the columns ordered by distance to the center.
+func centerFirst(columns int) []int {
+	order := make([]int, 0, columns)
+	center := (columns - 1) / 2
+	order = append(order, center)
+	for i := 1; len(order) < columns; i++ {
+		if columns%2 == 0 && center+i < columns {
+			order = append(order, center+i)
+		}
+		if center-i >= 0 {
+			order = append(order, center-i)
+		}
+		if columns%2 != 0 && center+i < columns {
+			order = append(order, center+i)
+		}
+	}
+	return order
+}
+
+// moves returns the legal moves, closest to the center first.
+func (s *search) moves() []engine.Move {
+	moves := s.f.Rules.LegalMoves(s.f)
+	for i := 1; i < len(moves); i++ {
+		for j := i; j > 0 && s.distance(moves[j]) < s.distance(moves[j-1]); j-- {
+			moves[j], moves[j-1] = moves[j-1], moves[j]
+		}
+	}
+	return moves
+}
+
+// distance returns how far from the center the move is played.
+// The row only matters for free placement, drops are all on row 0.
+func (s *search) distance(m engine.Move) int {
+	d := 2*m.Row - (s.f.Rows - 1)
+	if d < 0 {
+		d = -d
+	}
+	return s.rank[m.Column] + d
+}
+
+// root searches all the moves at the given depth, trying the first-th move first.
+// Returns the index of the best move and its score.
+func (s *search) root(moves []engine.Move, depth, first int) (int, int) {
+	best, alpha := -1, -maxScore
+	for i := -1; i < len(moves); i++ {
+		j := first
+		if i >= 0 {
+			if j = i; j == first {
+				continue
+			}
+		}
+		if j == -1 {
+			continue
+		}
+		_, _, _ = s.f.Play(moves[j])
+		v := s.child(depth-1, 1, alpha, maxScore, true)
+		_, _ = s.f.Undo()
+		if s.aborted {
+			return best, alpha
+		}
+		if best == -1 || v > alpha {
+			best, alpha = j, v
+		}
+	}
+	return best, alpha
+}
+
+// child evaluates the position after a move from the perspective of the side who played it.
+// side is true when the move was played by the searching player.
+func (s *search) child(depth, ply, alpha, beta int, side bool) int {
+	if s.f.SameTeam(s.f.CurPlayer, s.me) == side {
+		return s.negamax(depth, ply, alpha, beta)
+	}
+	return -s.negamax(depth, ply, -beta, -alpha)
+}
+
+// negamax returns the score of the position from the perspective of the side to move.
+func (s *search) negamax(depth, ply, alpha, beta int) int {
+	// Once ranked, our outcome is known, see engine.WithRanking.
+	if s.f.GridState != engine.Empty || s.f.Rank(s.me) != 0 {
+		return s.terminal(ply)
+	}
+	if depth == 0 {
+		return s.evaluate()
+	}
+
+	s.nodes++
+	if s.abortable && !s.deadline.IsZero() && s.nodes&1023 == 0 && time.Now().After(s.deadline) {
+		s.aborted = true
+	}
+	if s.aborted {
+		return 0
+	}
+
+	// Use the previous results for this position, if any.
+	alphaOrig, first := alpha, -1
+	key := s.f.Hash()
+	if e, ok := s.tt.Get(key); ok {
+		first = e.Move
+		if e.Depth >= depth {
+			v := fromTable(e.Score, ply)
+			switch e.Bound {
+			case engine.Exact:
+				return v
+			case engine.Lower:
+				if v > alpha {
+					alpha = v
+				}
+			case engine.Upper:
+				if v < beta {
+					beta = v
+				}
+			}
+			if alpha >= beta {
+				return v
+			}
+		}
+	}
+
+	side := s.f.SameTeam(s.f.CurPlayer, s.me)
+	moves := s.moves()
+	if first >= len(moves) {
+		first = -1 // Hash collision.
+	}
+	best, bestMove := -maxScore, -1
+	for i := -1; i < len(moves); i++ {
+		// Try the best move from the table first.
+		j := first
+		if i >= 0 {
+			if j = i; j == first {
+				continue
+			}
+		}
+		if j == -1 {
+			continue
+		}
+		_, _, _ = s.f.Play(moves[j])
+		v := s.child(depth-1, ply+1, alpha, beta, side)
+		_, _ = s.f.Undo()
+		if v > best {
+			best, bestMove = v, j
+		}
+		if v > alpha {
+			alpha = v
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	if s.aborted {
+		return best
+	}
+
+	bound := engine.Exact
+	if best <= alphaOrig {
+		bound = engine.Upper
+	} else if best >= beta {
+		bound = engine.Lower
+	}
+	s.tt.Put(engine.Entry{Key: key, Score: toTable(best, ply), Depth: depth, Move: bestMove, Bound: bound})
+	return best
+}
+
+// toTable converts a score relative to the root into one relative to the current node,
+// so wins found through transpositions keep the right distance.
+func toTable(v, ply int) int {
+	switch {
+	case v > winScore/2:
+		return v + ply
+	case v < -winScore/2:
+		return v - ply
+	}
+	return v
+}
+
+// fromTable is the inverse of toTable.
+func fromTable(v, ply int) int {
+	switch {
+	case v > winScore/2:
+		return v - ply
+	case v < -winScore/2:
+		return v + ply
+	}
+	return v
+}
+
+// terminal returns the score of a finished game from the perspective of the side to move.
+// Closer wins score higher.
+func (s *search) terminal(ply int) int {
+	v := winScore - ply
+	switch score := s.f.Rules.Score(s.f, s.me); {
+	case score >= 1:
+	case score <= 0:
+		v = -v
+	default:
+		return 0
+	}
+	if !s.f.SameTeam(s.f.CurPlayer, s.me) {
+		return -v
+	}
+	return v
+}
+
+// evaluate returns the heuristic score of the position from the perspective of the side to move.
+// Each window of nWin cells still open to a single player is worth exponentially more
+// the more pieces it holds.
+func (s *search) evaluate() int {
+	score := 0
+	for _, w := range s.f.Windows() {
+		owner, count := engine.State(engine.Empty), uint(0)
+		for _, c := range w {
+			st := s.f.State(c.Row, c.Col)
+			if st == engine.Empty {
+				continue
+			}
+			// Teammates share the window when lines may mix their colors.
+			if s.f.MixedTeams && s.f.SameTeam(st, owner) {
+				st = owner
+			}
+			if owner != engine.Empty && st != owner {
+				owner = engine.Empty
+				break
+			}
+			owner = st
+			count++
+		}
+		if owner == engine.Empty {
+			continue
+		}
+		if count > maxWeight {
+			count = maxWeight
+		}
+		if s.f.SameTeam(owner, s.me) {
+			score += 1 << (2 * count)
+		} else {
+			score -= 1 << (2 * count)
+		}
+	}
+	if score > maxEval {
+		score = maxEval
+	} else if score < -maxEval {
+		score = -maxEval
+	}
+	if !s.f.SameTeam(s.f.CurPlayer, s.me) {
+		return -score
+	}
+	return score
+}
@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Result kinds.
+const (
+	ResultWin     = "win"
+	ResultStale   = "stale"
+	ResultResign  = "resign"
+	ResultTimeout = "timeout"
+)
+
+// Result is the outcome of an archived game.
+type Result struct {
+	Kind   string `json:"kind"`
+	Player State  `json:"player,omitempty"` // Winner, or the player who resigned or timed out.
+	Line   Line   `json:"line,omitempty"`   // Winning line.
+
+	Placements []State `json:"placements,omitempty"` // Ranked players, first place first, see WithRanking.
+}
+
+// Archive is a complete game record, meant to be stored as JSON.
+type Archive struct {
+	Columns    int              `json:"columns"`
+	Rows       int              `json:"rows"`
+	NWin       int              `json:"nwin"`
+	NPlayers   int              `json:"nplayers"`
+	Variant    string           `json:"variant"`
+	Blockers   []Cell           `json:"blockers,omitempty"` // See WithBlockers.
+	Teams      [][]State        `json:"teams,omitempty"`    // See WithTeams.
+	MixedTeams bool             `json:"mixed_teams,omitempty"`
+	Ranking    bool             `json:"ranking,omitempty"` // See WithRanking.
+	Players    map[State]string `json:"players,omitempty"` // Player names.
+	Runtime    string           `json:"runtime,omitempty"` // Runtime the game was played on.
+	Moves      []Move           `json:"moves"`
+	Result     *Result          `json:"result,omitempty"` // Nil while the game is running.
+}
+
+// NewArchive returns the record of the given game.
+// The result is set when the game is finished, resign and timeout are up to the caller.
+func NewArchive(f *Four, runtime string) *Archive {
+	f.RLock()
+	players := make(map[State]string, len(f.Players))
+	for k, v := range f.Players {
+		players[k] = v
+	}
+	f.RUnlock()
+
+	a := &Archive{
+		Columns:    f.Columns,
+		Rows:       f.Rows,
+		NWin:       f.NWin,
+		NPlayers:   f.NPlayers,
+		Variant:    f.Variant,
+		Blockers:   f.Blockers,
+		Teams:      f.Teams,
+		MixedTeams: f.MixedTeams,
+		Ranking:    f.Ranking,
+		Players:    players,
+		Runtime:    runtime,
+		Moves:      append([]Move(nil), f.History...),
+	}
+	switch f.GridState {
+	case Empty:
+	case Stale:
+		a.Result = &Result{Kind: ResultStale}
+	default:
+		a.Result = &Result{Kind: ResultWin, Player: f.GridState, Placements: f.Placements}
+		if lines := f.lastLines(); len(lines) != 0 {
+			a.Result.Line = lines[0]
+		}
+	}
+	return a
+}
+
+// Encode writes the archive as JSON.
+func (a *Archive) Encode(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(a), "error encoding archive")
+}
+
+// DecodeArchive reads a JSON archive and validates it. See Replay.
+func DecodeArchive(r io.Reader) (*Archive, *Four, error) {
+	a := &Archive{}
+	if err := json.NewDecoder(r).Decode(a); err != nil {
+		return nil, nil, errors.Wrap(err, "error decoding archive")
+	}
+	f, err := a.Replay()
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, f, nil
+}
+
+// Replay creates a new game and replays every archived move through Play.
+// Fails if a move is illegal or if the archive does not match the replayed game.
+func (a *Archive) Replay() (*Four, error) {
+	variant := a.Variant
+	if variant == "" {
+		variant = DefaultVariant
+	}
+	opts := []Option{WithVariant(variant), WithBlockers(a.Blockers...), WithTeams(a.MixedTeams, a.Teams...)}
+	if a.Ranking {
+		opts = append(opts, WithRanking())
+	}
+	f, err := NewConnectFour(a.Columns, a.Rows, a.NPlayers, a.NWin, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid archive")
+	}
+	for p, name := range a.Players {
+		if !f.isPlayer(p) {
+			return nil, errors.Errorf("invalid archive, unknown player %d (%s)", p, name)
+		}
+		f.Players[p] = name
+	}
+
+	var lines []Line
+	for i, m := range a.Moves {
+		if f.GridState != Empty {
+			return nil, errors.Errorf("invalid archive, game finished before move %d", i+1)
+		}
+		if i > 0 && m.Time.Before(a.Moves[i-1].Time) {
+			return nil, errors.Errorf("invalid archive, move %d played before move %d", i+1, i)
+		}
+		var ret State
+		if ret, lines, err = f.Play(m); err != nil {
+			return nil, errors.Wrapf(err, "invalid archive, move %d", i+1)
+		}
+		played := &f.History[len(f.History)-1]
+		if m.Row != played.Row {
+			return nil, errors.Errorf("invalid archive, move %d landed on row %d, not %d", i+1, played.Row, m.Row)
+		}
+		if m.GridState != ret {
+			return nil, errors.Errorf("invalid archive, move %d ended with state %d, not %d", i+1, ret, m.GridState)
+		}
+		played.Time = m.Time
+	}
+
+	if err := a.checkResult(f, lines); err != nil {
+		return nil, errors.Wrap(err, "invalid archive")
+	}
+	return f, nil
+}
+
+// checkResult checks the archived result against the replayed game.
+// lines are the winning lines of the last move.
+func (a *Archive) checkResult(f *Four, lines []Line) error {
+	r := a.Result
+	if r == nil {
+		if f.GridState != Empty {
+			return errors.New("missing result of finished game")
+		}
+		return nil
+	}
+	switch r.Kind {
+	case ResultWin:
+		if f.GridState == Empty || f.GridState == Stale || r.Player != f.GridState {
+			return errors.Errorf("result claims a win for player %d, game state is %d", r.Player, f.GridState)
+		}
+		if len(r.Placements) != len(f.Placements) {
+			return errors.Errorf("result claims %d placements, game has %d", len(r.Placements), len(f.Placements))
+		}
+		for i, p := range r.Placements {
+			if p != f.Placements[i] {
+				return errors.Errorf("result claims player %d at place %d, game has player %d", p, i+1, f.Placements[i])
+			}
+		}
+		if r.Line == nil {
+			return nil
+		}
+		if hasLine(lines, r.Line) {
+			return nil
+		}
+		return errors.Errorf("result line %v is not a winning line", r.Line)
+	case ResultStale:
+		if f.GridState != Stale {
+			return errors.Errorf("result claims a stale, game state is %d", f.GridState)
+		}
+	case ResultResign, ResultTimeout:
+		if f.GridState != Empty {
+			return errors.Errorf("result claims a %s, game state is %d", r.Kind, f.GridState)
+		}
+		if !f.isPlayer(r.Player) {
+			return errors.Errorf("unknown %s player %d", r.Kind, r.Player)
+		}
+	default:
+		return errors.Errorf("unknown result %q", r.Kind)
+	}
+	if r.Line != nil {
+		return errors.Errorf("unexpected line for a %s", r.Kind)
+	}
+	if r.Placements != nil {
+		return errors.Errorf("unexpected placements for a %s", r.Kind)
+	}
+	return nil
+}
+
+// isPlayer returns true if p is one of the players of the game.
+func (f *Four) isPlayer(p State) bool {
+	for _, elem := range f.AvailablePlayers {
+		if elem == p {
+			return true
+		}
+	}
+	return false
+}
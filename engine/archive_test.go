@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestArchiveCorrupted checks the replay rejects every corrupted field of an archive.
+func TestArchiveCorrupted(t *testing.T) {
+	// Red wins in column 0 on its 4th move.
+	f, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Players[Red], f.Players[Yellow] = "alice", "bob"
+	for _, col := range []int{0, 1, 0, 1, 0, 1, 0} {
+		if _, _, err := f.PlayerMove(f.CurPlayer, col); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base := bytes.NewBuffer(nil)
+	if err := NewArchive(f, "test").Encode(base); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := DecodeArchive(bytes.NewReader(base.Bytes())); err != nil {
+		t.Fatalf("Unexpected error replaying the archive: %s", err)
+	}
+
+	// running drops the winning move, leaving a running game.
+	running := func(a *Archive) { a.Moves = a.Moves[:len(a.Moves)-1] }
+	for _, tc := range []struct {
+		name   string
+		edit   func(a *Archive)
+		expect string
+	}{
+		{name: "columns", edit: func(a *Archive) { a.Columns = 0 }, expect: "invalid grid size: 0/6"},
+		{name: "rows", edit: func(a *Archive) { a.Rows = -1 }, expect: "invalid grid size: 7/-1"},
+		{name: "nwin", edit: func(a *Archive) { a.NWin = 1 }, expect: "invalid win number: 1"},
+		{name: "nplayers", edit: func(a *Archive) { a.NPlayers = 0 }, expect: "invalid player count: 0"},
+		{name: "variant", edit: func(a *Archive) { a.Variant = "chess" }, expect: "unknown variant 'chess'"},
+		{name: "blockers", edit: func(a *Archive) { a.Blockers = []Cell{{Row: 6, Col: 0}} }, expect: "blocker 0/6 outside of the grid"},
+		{name: "unknown player", edit: func(a *Archive) { a.Players[Blue] = "eve" }, expect: "unknown player 5 (eve)"},
+		{name: "move column", edit: func(a *Archive) { a.Moves[2].Column = 7 }, expect: "move 3: 7 is an invalid move"},
+		{name: "move player", edit: func(a *Archive) { a.Moves[1].Player = Red }, expect: "move 2: invalid move, not player's turn"},
+		{name: "move pop", edit: func(a *Archive) { a.Moves[2].Pop = true }, expect: "move 3: pop not allowed in this variant"},
+		{name: "move row", edit: func(a *Archive) { a.Moves[0].Row = 2 }, expect: "move 1 landed on row 5, not 2"},
+		{name: "move state", edit: func(a *Archive) { a.Moves[6].GridState = Empty }, expect: "move 7 ended with state 1, not 0"},
+		{name: "move time", edit: func(a *Archive) { a.Moves[3].Time = a.Moves[2].Time.Add(-time.Second) }, expect: "move 4 played before move 3"},
+		{name: "move after the end", edit: func(a *Archive) { a.Moves = append(a.Moves, a.Moves[1]) }, expect: "game finished before move 8"},
+		{name: "missing result", edit: func(a *Archive) { a.Result = nil }, expect: "missing result of finished game"},
+		{name: "result kind", edit: func(a *Archive) { a.Result.Kind = "draw" }, expect: `unknown result "draw"`},
+		{name: "result winner", edit: func(a *Archive) { a.Result.Player = Yellow }, expect: "result claims a win for player 2, game state is 1"},
+		{name: "result win of a running game", edit: running, expect: "result claims a win for player 1, game state is 0"},
+		{name: "result stale", edit: func(a *Archive) { a.Result = &Result{Kind: ResultStale} }, expect: "result claims a stale, game state is 1"},
+		{name: "result resign of a finished game", edit: func(a *Archive) { a.Result = &Result{Kind: ResultResign, Player: Yellow} }, expect: "result claims a resign, game state is 1"},
+		{name: "result line", edit: func(a *Archive) {
+			a.Result.Line = Line{{Row: 5, Col: 1}, {Row: 4, Col: 1}, {Row: 3, Col: 1}, {Row: 2, Col: 1}}
+		}, expect: "is not a winning line"},
+		{name: "result placements", edit: func(a *Archive) { a.Result.Placements = []State{Red} }, expect: "result claims 1 placements, game has 0"},
+		{name: "result timeout player", edit: func(a *Archive) {
+			running(a)
+			a.Result = &Result{Kind: ResultTimeout, Player: Green}
+		}, expect: "unknown timeout player 3"},
+		{name: "result resign line", edit: func(a *Archive) {
+			running(a)
+			a.Result = &Result{Kind: ResultResign, Player: Yellow, Line: a.Result.Line}
+		}, expect: "unexpected line for a resign"},
+		{name: "result resign placements", edit: func(a *Archive) {
+			running(a)
+			a.Result = &Result{Kind: ResultResign, Player: Yellow, Placements: []State{Red}}
+		}, expect: "unexpected placements for a resign"},
+	} {
+		a, _, err := DecodeArchive(bytes.NewReader(base.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.edit(a)
+		buf := bytes.NewBuffer(nil)
+		if err := a.Encode(buf); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = DecodeArchive(buf)
+		if err == nil || !strings.Contains(err.Error(), tc.expect) {
+			t.Fatalf("[%s] Unexpected error.\nExpected:\t%s\nGot:\t\t%v", tc.name, tc.expect, err)
+		}
+	}
+
+	if _, _, err := DecodeArchive(strings.NewReader(`{"columns": "7"}`)); err == nil || !strings.Contains(err.Error(), "error decoding archive") {
+		t.Fatalf("Unexpected error decoding invalid JSON: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package engine
+
+import "github.com/pkg/errors"
+
+// ErrBitboardTooLarge is returned when the grid does not fit in a single word.
+var ErrBitboardTooLarge = errors.New("grid too large for a bitboard")
+
+// Bitboard is a compact representation of the grid with one bitmask per state.
+//
+// Cells are laid out column by column, from the bottom up, with an extra
+// always empty sentinel bit on top of each column so that shifting a mask
+// never bleeds from one column into the next.
+// Sample layout for the default 7x6 grid:
+//
+//	.  .  .  .  .  .  .
+//	5 12 19 26 33 40 47
+//	4 11 18 25 32 39 46
+//	3 10 17 24 31 38 45
+//	2  9 16 23 30 37 44
+//	1  8 15 22 29 36 43
+//	0  7 14 21 28 35 42
+//
+// Rows are addressed the same way as Four.Content: row 0 is the top of the grid.
+type Bitboard struct {
+	columns int
+	rows    int
+	nWin    int
+	height  int // Bits per column, rows + sentinel.
+
+	masks    [Blocker + 1]uint64 // Cells owned by each player and blocked cells, indexed by State.
+	occupied uint64              // Union of all the masks.
+	top      uint64              // Top row of the grid.
+	dirs     [4]uint             // Shift for each line direction.
+}
+
+// BitboardFits returns true if a grid of the given size fits in a Bitboard.
+func BitboardFits(columns, rows int) bool {
+	return columns > 0 && rows > 0 && columns*(rows+1) <= 64
+}
+
+// NewBitboard instantiates an empty bitboard.
+// Returns ErrBitboardTooLarge if the grid does not fit, see BitboardFits.
+func NewBitboard(columns, rows, nWin int) (*Bitboard, error) {
+	if !BitboardFits(columns, rows) {
+		return nil, errors.Wrapf(ErrBitboardTooLarge, "%dx%d", columns, rows)
+	}
+	b := &Bitboard{
+		columns: columns,
+		rows:    rows,
+		nWin:    nWin,
+		height:  rows + 1,
+	}
+	for i := 0; i < columns; i++ {
+		b.top |= b.bit(0, i)
+	}
+	b.dirs = [4]uint{
+		1,                  // Vertical.
+		uint(b.height),     // Horizontal.
+		uint(b.height + 1), // Diagonal.
+		uint(b.height - 1), // Anti-diagonal.
+	}
+	return b, nil
+}
+
+// bit returns the mask of the given cell.
+func (b *Bitboard) bit(row, col int) uint64 {
+	return 1 << uint(col*b.height+b.rows-1-row)
+}
+
+// columnMask returns the mask of the playable cells of the given column.
+func (b *Bitboard) columnMask(col int) uint64 {
+	return (1<<uint(b.rows) - 1) << uint(col*b.height)
+}
+
+// State returns the state of the given cell.
+func (b *Bitboard) State(row, col int) State {
+	bit := b.bit(row, col)
+	if b.occupied&bit == 0 {
+		return Empty
+	}
+	for i, m := range b.masks {
+		if m&bit != 0 {
+			return State(i)
+		}
+	}
+	return Empty
+}
+
+// Set sets the state of the given cell. Setting Empty clears the cell.
+func (b *Bitboard) Set(row, col int, s State) {
+	bit := b.bit(row, col)
+	if b.occupied&bit != 0 {
+		for i := range b.masks {
+			b.masks[i] &^= bit
+		}
+		b.occupied &^= bit
+	}
+	if s == Empty {
+		return
+	}
+	b.masks[s] |= bit
+	b.occupied |= bit
+}
+
+// Height returns the number of cells between the bottom of the column
+// and its topmost occupied cell, inclusive.
+func (b *Bitboard) Height(col int) int {
+	occ := (b.occupied & b.columnMask(col)) >> uint(col*b.height)
+	n := 0
+	for ; occ != 0; occ >>= 1 {
+		n++
+	}
+	return n
+}
+
+// Landing returns the row where a piece dropped in the given column would land.
+// Returns -1 if the column is full.
+func (b *Bitboard) Landing(col int) int {
+	return b.rows - 1 - b.Height(col)
+}
+
+// Full returns true if the top row is complete.
+func (b *Bitboard) Full() bool {
+	return b.occupied&b.top == b.top
+}
+
+// Wins returns true if the given players, together, have nWin in a row.
+func (b *Bitboard) Wins(players ...State) bool {
+	var m uint64
+	for _, s := range players {
+		m |= b.masks[s]
+	}
+	return b.hasLine(m)
+}
+
+// LineThrough returns true if the given players, together, have nWin in a row
+// going through the given cell.
+func (b *Bitboard) LineThrough(row, col int, players ...State) bool {
+	var m uint64
+	for _, s := range players {
+		m |= b.masks[s]
+	}
+	bit := b.bit(row, col)
+	if m&bit == 0 {
+		return false
+	}
+	for _, d := range b.dirs {
+		// Keep the bits starting a run, along with the starts of the runs covering the cell.
+		x, starts := m, bit
+		for i := uint(1); i < uint(b.nWin) && x != 0; i++ {
+			x &= x >> d
+			starts |= bit >> (i * d)
+		}
+		if x&starts != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLine returns true if the given mask has nWin aligned bits in any direction.
+func (b *Bitboard) hasLine(m uint64) bool {
+	for _, d := range b.dirs {
+		// Each iteration keeps the bits starting a run one cell longer.
+		x := m
+		for i := 1; i < b.nWin && x != 0; i++ {
+			x &= x >> d
+		}
+		if x != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// randomGame plays random moves on the game until it ends or maxMoves are played.
+// check is called after each move.
+func randomGame(t testing.TB, f *Four, rnd *rand.Rand, maxMoves int, check func()) {
+	for i := 0; i < maxMoves && f.GridState == Empty; i++ {
+		moves := f.Rules.LegalMoves(f)
+		if len(moves) == 0 {
+			return
+		}
+		if _, _, err := f.Play(moves[rnd.Intn(len(moves))]); err != nil {
+			t.Fatalf("Unexpected error playing a legal move: %s", err)
+		}
+		if check != nil {
+			check()
+		}
+	}
+}
+
+// checkBitboard fails if the bitboard does not mirror the grid.
+func checkBitboard(t *testing.T, f *Four) {
+	for x := range f.Content {
+		for y, s := range f.Content[x] {
+			if got := f.board.State(x, y); got != s {
+				t.Fatalf("Unexpected bitboard state at %d/%d.\nExpected:\t%d\nGot:\t\t%d", x, y, s, got)
+			}
+		}
+	}
+	for y := 0; y < f.Columns; y++ {
+		expect := -1
+		for expect+1 < f.Rows && f.Content[expect+1][y] == Empty {
+			expect++
+		}
+		if got := f.board.Landing(y); got != expect {
+			t.Fatalf("Unexpected landing row in column %d.\nExpected:\t%d\nGot:\t\t%d", y, expect, got)
+		}
+	}
+	full := true
+	for _, s := range f.Content[0] {
+		full = full && s != Empty
+	}
+	if got := f.board.Full(); got != full {
+		t.Fatalf("Unexpected full grid.\nExpected:\t%t\nGot:\t\t%t", full, got)
+	}
+}
+
+func TestBitboardFits(t *testing.T) {
+	for _, elem := range []struct {
+		columns, rows int
+		fits          bool
+	}{
+		{7, 6, true},
+		{8, 7, true},
+		{9, 6, true},
+		{9, 7, false},
+		{16, 3, true},
+		{16, 4, false},
+		{0, 6, false},
+	} {
+		if got := BitboardFits(elem.columns, elem.rows); got != elem.fits {
+			t.Errorf("Unexpected result for %dx%d.\nExpected:\t%t\nGot:\t\t%t", elem.columns, elem.rows, elem.fits, got)
+		}
+		if _, err := NewBitboard(elem.columns, elem.rows, 4); (err == nil) != elem.fits {
+			t.Errorf("Unexpected error for %dx%d: %v", elem.columns, elem.rows, err)
+		}
+	}
+}
+
+// TestBitboardScan plays random games and checks that the bitboard mirrors
+// the grid and finds the same winner and lines as the cell by cell scan.
+func TestBitboardScan(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for n := 0; n < 1000; n++ {
+		cols, rows := 2+rnd.Intn(8), 2+rnd.Intn(7)
+		f, err := NewConnectFour(cols, rows, 1+rnd.Intn(4), 2+rnd.Intn(5))
+		if err != nil || f.board == nil {
+			continue
+		}
+		randomGame(t, f, rnd, cols*rows, func() {
+			checkBitboard(t, f)
+			if expect, got := f.scan(), f.compute(); got != expect {
+				t.Fatalf("Unexpected winner on %dx%d, nWin %d.\nExpected:\t%d\nGot:\t\t%d", cols, rows, f.NWin, expect, got)
+			}
+			// The lines of the last move, walking the grid and shifting the bitboard.
+			m := f.History[len(f.History)-1]
+			board := f.board
+			f.board = nil
+			expect := len(f.LinesFrom(m.Row, m.Column)) != 0
+			f.board = board
+			if got := board.LineThrough(m.Row, m.Column, m.Player); got != expect {
+				t.Fatalf("Unexpected line through %d/%d on %dx%d, nWin %d.\nExpected:\t%t\nGot:\t\t%t", m.Column, m.Row, cols, rows, f.NWin, expect, got)
+			}
+		})
+		// Taking back every move empties the bitboard.
+		for len(f.History) != 0 {
+			if _, err := f.Undo(); err != nil {
+				t.Fatal(err)
+			}
+			checkBitboard(t, f)
+		}
+	}
+}
+
+// benchGame returns a game of the default size, played halfway, with the given representation.
+func benchGame(b *testing.B, bitboard bool) *Four {
+	f, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if !bitboard {
+		f.board = nil
+	}
+	for _, col := range []int{3, 6, 5, 0, 4, 0, 5, 0, 0, 4, 0, 2, 1, 4, 6, 4, 1, 3} {
+		if _, _, err := f.PlayerMove(f.CurPlayer, col); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if f.GridState != Empty {
+		b.Fatal("Unexpected end of the benchmark game")
+	}
+	return f
+}
+
+func benchmarkPlay(b *testing.B, bitboard bool) {
+	f := benchGame(b, bitboard)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := f.PlayerMove(f.CurPlayer, i%f.Columns); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := f.Undo(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkPlayRescan plays as before the bitboard and the last move checks:
+// the piece is dropped in the grid, then the whole grid is scanned for a line
+// and for a free cell.
+func BenchmarkPlayRescan(b *testing.B) {
+	f := benchGame(b, false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		col := i % f.Columns
+		row := f.Landing(col)
+		f.Content[row][col] = f.CurPlayer
+		if f.scan() != Empty || f.Full() {
+			b.Fatal("Unexpected end of the game")
+		}
+		f.Content[row][col] = Empty
+	}
+}
+
+func benchmarkCompute(b *testing.B, bitboard bool) {
+	f := benchGame(b, bitboard)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if f.compute() != Empty {
+			b.Fatal("Unexpected winner")
+		}
+	}
+}
+
+// benchmarkTerminal checks each move of the game for a win, as Play does for the last one.
+func benchmarkTerminal(b *testing.B, bitboard bool) {
+	f := benchGame(b, bitboard)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := f.History[i%len(f.History)]
+		if len(f.LinesFrom(m.Row, m.Column)) != 0 {
+			b.Fatal("Unexpected winner")
+		}
+	}
+}
+
+// The Scan and Grid benchmarks run without the bitboard. PlayGrid still only
+// checks the lines of the last move, see BenchmarkPlayRescan for the full scan.
+func BenchmarkPlay(b *testing.B)         { benchmarkPlay(b, true) }
+func BenchmarkPlayGrid(b *testing.B)     { benchmarkPlay(b, false) }
+func BenchmarkTerminal(b *testing.B)     { benchmarkTerminal(b, true) }
+func BenchmarkTerminalScan(b *testing.B) { benchmarkTerminal(b, false) }
+func BenchmarkCompute(b *testing.B)      { benchmarkCompute(b, true) }
+func BenchmarkComputeScan(b *testing.B)  { benchmarkCompute(b, false) }
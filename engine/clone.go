@@ -0,0 +1,131 @@
+package engine
+
+// Clone returns a deep copy of the game, safe to mutate independently.
+// The copy has its own lock and no subscribers, so its moves are not
+// reported to the original game's listeners.
+func (f *Four) Clone() *Four {
+	f.RLock()
+	players := make(map[State]string, len(f.Players))
+	for k, v := range f.Players {
+		players[k] = v
+	}
+	f.RUnlock()
+
+	g := &Four{
+		Content:          make([][]State, len(f.Content)),
+		NWin:             f.NWin,
+		NPlayers:         f.NPlayers,
+		Columns:          f.Columns,
+		Rows:             f.Rows,
+		CurPlayerIdx:     f.CurPlayerIdx,
+		CurPlayer:        f.CurPlayer,
+		AvailablePlayers: append([]State(nil), f.AvailablePlayers...),
+		Players:          players,
+		GridState:        f.GridState,
+		WinningLines:     f.WinningLines, // Read only, can be shared.
+		History:          append([]Move(nil), f.History...),
+		Blockers:         f.Blockers, // Read only, can be shared.
+		Rules:            f.Rules,    // Stateless, can be shared.
+		Variant:          f.Variant,
+		Teams:            f.Teams, // Read only, can be shared.
+		MixedTeams:       f.MixedTeams,
+		Ranking:          f.Ranking,
+		Placements:       append([]State(nil), f.Placements...),
+		redo:             append([]Move(nil), f.redo...),
+		windows:          f.windows, // Read only, can be shared.
+		wrap:             f.wrap,
+		zobrist:          f.zobrist, // Read only, can be shared.
+		hash:             f.hash,
+	}
+	// Use a single allocation for the whole grid.
+	cells := make([]State, f.Rows*f.Columns)
+	for i, row := range f.Content {
+		g.Content[i] = cells[i*f.Columns : (i+1)*f.Columns : (i+1)*f.Columns]
+		copy(g.Content[i], row)
+	}
+	if f.board != nil {
+		board := *f.board
+		g.board = &board
+	}
+	if f.live != nil {
+		g.live = f.live.clone()
+	}
+	return g
+}
+
+// Position is an immutable snapshot of a game position.
+type Position struct {
+	columns   int
+	rows      int
+	nWin      int
+	nPlayers  int
+	cells     []State // Row major.
+	history   []Move
+	curPlayer State
+	gridState State
+	hash      uint64
+}
+
+// Position returns a snapshot of the current position.
+func (f *Four) Position() Position {
+	p := Position{
+		columns:   f.Columns,
+		rows:      f.Rows,
+		nWin:      f.NWin,
+		nPlayers:  f.NPlayers,
+		cells:     make([]State, 0, f.Rows*f.Columns),
+		history:   append([]Move(nil), f.History...),
+		curPlayer: f.CurPlayer,
+		gridState: f.GridState,
+		hash:      f.Hash(),
+	}
+	for _, row := range f.Content {
+		p.cells = append(p.cells, row...)
+	}
+	return p
+}
+
+// Columns returns the number of columns of the grid.
+func (p Position) Columns() int {
+	return p.columns
+}
+
+// Rows returns the number of rows of the grid.
+func (p Position) Rows() int {
+	return p.rows
+}
+
+// NWin returns the number of aligned pieces needed to win.
+func (p Position) NWin() int {
+	return p.nWin
+}
+
+// NPlayers returns the number of players.
+func (p Position) NPlayers() int {
+	return p.nPlayers
+}
+
+// State returns the state of the grid at the x/y position, as Four.State.
+func (p Position) State(x, y int) State {
+	return p.cells[x*p.columns+y]
+}
+
+// CurPlayer returns the player to move.
+func (p Position) CurPlayer() State {
+	return p.curPlayer
+}
+
+// GridState returns the state of the game, see Four.GridState.
+func (p Position) GridState() State {
+	return p.gridState
+}
+
+// Hash returns the hash of the position, see Four.Hash.
+func (p Position) Hash() uint64 {
+	return p.hash
+}
+
+// History returns a copy of the moves played to reach the position.
+func (p Position) History() []Move {
+	return append([]Move(nil), p.history...)
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// snapshot is the mutable state of a game, deeply copied.
+type snapshot struct {
+	content    [][]State
+	board      *Bitboard
+	live       *liveness
+	history    []Move
+	redo       []Move
+	placements []State
+	players    map[State]string
+	hash       uint64
+	curPlayer  State
+	gridState  State
+	lines      []Line
+}
+
+func takeSnapshot(f *Four) snapshot {
+	s := snapshot{
+		history:    append([]Move(nil), f.History...),
+		redo:       append([]Move(nil), f.redo...),
+		placements: append([]State(nil), f.Placements...),
+		players:    map[State]string{},
+		hash:       f.Hash(),
+		curPlayer:  f.CurPlayer,
+		gridState:  f.GridState,
+		lines:      f.WinningLines,
+	}
+	for _, row := range f.Content {
+		s.content = append(s.content, append([]State(nil), row...))
+	}
+	if f.board != nil {
+		board := *f.board
+		s.board = &board
+	}
+	s.live = f.live.clone()
+	for k, v := range f.Players {
+		s.players[k] = v
+	}
+	return s
+}
+
+// TestCloneIndependent mutates clones of random games and checks the original is untouched.
+func TestCloneIndependent(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, opts := range [][]Option{
+		nil,
+		{WithVariant("popout")},
+		{WithVariant("free")},
+		{WithRanking()},
+	} {
+		for n := 0; n < 100; n++ {
+			f, err := NewConnectFour(2+rnd.Intn(9), 2+rnd.Intn(9), 2+rnd.Intn(3), 2+rnd.Intn(4), opts...)
+			if err != nil {
+				continue
+			}
+			f.Players[Red] = "red"
+			randomGame(t, f, rnd, rnd.Intn(f.Columns*f.Rows), nil)
+			if len(f.History) != 0 && rnd.Intn(2) == 0 {
+				if _, err := f.Undo(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Finished games have no subscribers.
+			var activity chan State
+			if f.GridState == Empty {
+				activity = f.Subscribe()
+			}
+			before := takeSnapshot(f)
+			pos := f.Position()
+
+			g := f.Clone()
+			if !reflect.DeepEqual(takeSnapshot(g), before) {
+				t.Fatalf("Unexpected clone of %s", f.Notation())
+			}
+			// Take back everything, replay and play on.
+			for len(g.History) != 0 {
+				if _, err := g.Undo(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for {
+				if _, _, err := g.Redo(); err != nil {
+					break
+				}
+			}
+			randomGame(t, g, rnd, 10, nil)
+			g.Players[Yellow] = "yellow"
+			g.Release()
+
+			if after := takeSnapshot(f); !reflect.DeepEqual(after, before) {
+				t.Fatalf("Unexpected change of the original %s after mutating its clone", f.Notation())
+			}
+			if pos.Hash() != f.Hash() || len(pos.History()) != len(f.History) {
+				t.Fatalf("Unexpected position snapshot of %s", f.Notation())
+			}
+			select {
+			case s, ok := <-activity:
+				t.Fatalf("Unexpected notification of the original after mutating its clone: %d, %t", s, ok)
+			default:
+			}
+			f.Unsubscribe(activity)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package engine
+
+import "github.com/pkg/errors"
+
+// Cylinder is the variant where the grid is rolled into a cylinder: the lines
+// wrap from the rightmost column back to the leftmost one.
+type Cylinder struct {
+	Classic
+}
+
+// Name returns the name of the variant.
+func (Cylinder) Name() string {
+	return "cylinder"
+}
+
+// Init rejects grids narrower than nWin, where a wrapped line would reuse the same cell.
+func (Cylinder) Init(f *Four) error {
+	if f.NWin > f.Columns {
+		return errors.Errorf("%d columns are too few to wrap lines of %d", f.Columns, f.NWin)
+	}
+	f.wrap = true
+	return nil
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCylinder plays fixed games where the winning line wraps across the edge
+// of the grid, and checks the same moves on a flat grid.
+func TestCylinder(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		columns, nWin int
+		moves         []int
+		line          Line
+		flat          State // State of the same moves on a flat grid.
+	}{
+		{
+			name: "horizontal", columns: 7, nWin: 4,
+			moves: []int{5, 3, 6, 3, 0, 3, 1},
+			line:  Line{{Row: 5, Col: 5}, {Row: 5, Col: 6}, {Row: 5, Col: 0}, {Row: 5, Col: 1}},
+		},
+		{
+			name: "diagonal", columns: 7, nWin: 4,
+			moves: []int{5, 6, 0, 1, 1, 0, 0, 1, 1, 3, 6},
+			line:  Line{{Row: 5, Col: 5}, {Row: 4, Col: 6}, {Row: 3, Col: 0}, {Row: 2, Col: 1}},
+		},
+		{
+			// As many columns as nWin, the row is a single line, not one per starting column.
+			name: "full row", columns: 4, nWin: 4,
+			moves: []int{1, 1, 2, 2, 3, 3, 0},
+			line:  Line{{Row: 5, Col: 0}, {Row: 5, Col: 1}, {Row: 5, Col: 2}, {Row: 5, Col: 3}},
+			flat:  Red,
+		},
+	} {
+		for _, variant := range []string{"cylinder", "classic"} {
+			f, err := NewConnectFour(tc.columns, DefaultRows, DefaultNPlayers, tc.nWin, WithVariant(variant))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, col := range tc.moves {
+				if _, _, err := f.PlayerMove(f.CurPlayer, col); err != nil {
+					t.Fatal(err)
+				}
+				if i < len(tc.moves)-1 && f.GridState != Empty {
+					t.Fatalf("[%s %s] Unexpected end of the game on move %d: %d", tc.name, variant, i+1, f.GridState)
+				}
+			}
+			if variant == "classic" {
+				if f.GridState != tc.flat {
+					t.Fatalf("[%s] Unexpected end of the game without wrapping.\nExpected:\t%d\nGot:\t\t%d %v", tc.name, tc.flat, f.GridState, f.WinningLines)
+				}
+				continue
+			}
+			if f.GridState != Red || len(f.WinningLines) != 1 || !sameCells(f.WinningLines[0], tc.line) {
+				t.Fatalf("[%s] Unexpected end of the game.\nExpected:\t%d %v\nGot:\t\t%d %v", tc.name, Red, tc.line, f.GridState, f.WinningLines)
+			}
+			if got, lines := f.Scan(); got != Red || len(lines) != 1 || !sameCells(lines[0], tc.line) {
+				t.Fatalf("[%s] Unexpected rescan.\nExpected:\t%d %v\nGot:\t\t%d %v", tc.name, Red, tc.line, got, lines)
+			}
+		}
+	}
+}
+
+// TestCylinderInit checks the grids narrower than a line are rejected, a
+// wrapped line would reuse the same cell.
+func TestCylinderInit(t *testing.T) {
+	if _, err := NewConnectFour(3, DefaultRows, DefaultNPlayers, 4, WithVariant("cylinder")); err == nil || !strings.Contains(err.Error(), "3 columns are too few to wrap lines of 4") {
+		t.Fatalf("Unexpected error creating a narrow cylinder: %v", err)
+	}
+	// The flat grid is fine, the lines are vertical.
+	if _, err := NewConnectFour(3, DefaultRows, DefaultNPlayers, 4); err != nil {
+		t.Fatalf("Unexpected error creating a narrow grid: %s", err)
+	}
+	f, err := NewConnectFour(4, DefaultRows, DefaultNPlayers, 4, WithVariant("cylinder"))
+	if err != nil {
+		t.Fatalf("Unexpected error creating a cylinder as wide as a line: %s", err)
+	}
+	if !f.Wraps() {
+		t.Fatal("Unexpected flat cylinder")
+	}
+}
@@ -0,0 +1,73 @@
+package engine
+
+import "github.com/pkg/errors"
+
+// Placement is implemented by the rules where pieces can be placed on any cell.
+// Moves are then addressed by column and row.
+type Placement interface {
+	Rules
+	FreePlacement()
+}
+
+// FreePlacement returns true if the moves are addressed by column and row. See Placement.
+func (f *Four) FreePlacement() bool {
+	_, ok := f.Rules.(Placement)
+	return ok
+}
+
+// Free is the variant without gravity: pieces are placed on any empty cell,
+// making the game a generic m,n,k-game. E.g. tic-tac-toe on a 3x3 grid with 3
+// to win, Gomoku on a 15x15 grid with 5 to win.
+type Free struct {
+	Classic
+}
+
+// FreePlacement implements Placement.
+func (Free) FreePlacement() {}
+
+// Name returns the name of the variant.
+func (Free) Name() string {
+	return "free"
+}
+
+// LegalMoves returns every empty cell.
+func (Free) LegalMoves(f *Four) []Move {
+	var moves []Move
+	for x, row := range f.Content {
+		for y, s := range row {
+			if s == Empty {
+				moves = append(moves, Move{Player: f.CurPlayer, Column: y, Row: x})
+			}
+		}
+	}
+	return moves
+}
+
+// Validate checks if the cell exists and is empty.
+func (Free) Validate(f *Four, m Move) error {
+	if m.Pop {
+		return ErrNoPopOut
+	}
+	if !f.inside(m.Row, m.Column) || f.Content[m.Row][m.Column] != Empty {
+		return errors.Wrapf(ErrInvalidMove, "%d/%d is an invalid move for player %d (%s)", m.Column, m.Row, m.Player, m.Player)
+	}
+	return nil
+}
+
+// Apply places the piece.
+func (Free) Apply(f *Four, m Move) Move {
+	f.Set(m.Row, m.Column, m.Player)
+	return m
+}
+
+// Terminal checks the lines going through the new piece, then if every cell is taken
+// or blocked or if no line can be completed anymore.
+func (Free) Terminal(f *Four, m Move) (State, []Line) {
+	if lines := f.LinesFrom(m.Row, m.Column); len(lines) != 0 {
+		return m.Player, lines
+	}
+	if len(f.History)+len(f.Blockers) == f.Rows*f.Columns || !f.Live() {
+		return Stale, nil
+	}
+	return Empty, nil
+}
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -9,6 +10,8 @@ import (
 // Common errors.
 var (
 	ErrInvalidMove = errors.New("invalid move")
+	ErrNoMove      = errors.New("no move available")
+	ErrGameOver    = errors.New("game is over")
 )
 
 // Common defaults.
@@ -18,11 +21,14 @@ const (
 	DefaultNPlayers = 2
 	DefaultNWin     = 4
 	DefaultMode     = "terminal"
+	DefaultVariant  = "classic"
+
+	MaxNWin = 255 // The pieces of each window are counted on a byte.
 )
 
 // Four holds the game state.
 type Four struct {
-	sync.RWMutex // Lock to protect the Players map.
+	sync.RWMutex // Lock to protect the Players map and the subscribers.
 
 	Content  [][]State `json:"content"`
 	NWin     int       `json:"nwin"`
@@ -36,22 +42,50 @@ type Four struct {
 	AvailablePlayers []State          `json:"available_players"`
 	Players          map[State]string `json:"players"` // Used for the server mode.
 
-	ActivityChan chan State `json:"-"`          // Channel populated with latest game state.
-	GridState    State      `json:"grid_state"` // If not "Empty", then the game is finished.
+	GridState    State  `json:"grid_state"`              // If not "Empty", then the game is finished.
+	WinningLines []Line `json:"winning_lines,omitempty"` // Lines of the winner once the game is won.
+
+	History  []Move `json:"history"`            // Moves played so far.
+	Blockers []Cell `json:"blockers,omitempty"` // Neutral cells set before the game, see WithBlockers.
+	Rules    Rules  `json:"-"`                  // Rules of the variant played.
+	Variant  string `json:"variant"`            // Name of the rules.
+
+	Teams      [][]State `json:"teams,omitempty"`       // Players playing together, see WithTeams.
+	MixedTeams bool      `json:"mixed_teams,omitempty"` // Lines may mix the colors of a team.
+
+	Ranking    bool    `json:"ranking,omitempty"`    // Play on once a player wins, see WithRanking.
+	Placements []State `json:"placements,omitempty"` // Players ranked so far, first place first.
+
+	board   *Bitboard    // Mirror of Content, nil when the grid is too large.
+	redo    []Move       // Moves taken back, last one first to be replayed.
+	windows *windowIndex // Windows of the grid, set once the rules are initialized.
+	live    *liveness    // Pieces of the windows, see Live.
+	wrap    bool         // Lines wrap around the columns, see Cylinder.
+	zobrist *zobrist     // Hashing keys for this configuration.
+	hash    uint64       // Zobrist hash of Content, see Hash.
+
+	subs     map[chan State]bool // Listeners of the game changes, see Subscribe.
+	released bool                // No more listeners, see Release.
 }
 
-// NewConnectFour instantiates a new game.
+// NewConnectFour instantiates a new game. Uses the Classic rules unless told otherwise.
 // TODO: Use a single dimension slice.
-func NewConnectFour(columns, rows, nPlayers, nWin int) (*Four, error) {
+func NewConnectFour(columns, rows, nPlayers, nWin int, opts ...Option) (*Four, error) {
 	if columns < 2 || rows < 2 {
 		return nil, errors.Errorf("invalid grid size: %d/%d", columns, rows)
 	}
+	if nPlayers < 1 {
+		return nil, errors.Errorf("invalid player count: %d", nPlayers)
+	}
 	if nPlayers > len(AvailablePlayers) {
 		return nil, errors.Errorf("too many players. Max: %d", len(AvailablePlayers))
 	}
 	if nWin < 2 {
 		return nil, errors.Errorf("invalid win number: %d, minimum 2", nWin)
 	}
+	if nWin > MaxNWin {
+		return nil, errors.Errorf("invalid win number: %d, maximum %d", nWin, MaxNWin)
+	}
 	if columns < nWin && rows < nWin {
 		return nil, errors.New("grid too small for anyone to win")
 	}
@@ -59,7 +93,10 @@ func NewConnectFour(columns, rows, nPlayers, nWin int) (*Four, error) {
 	for i := range content {
 		content[i] = make([]State, columns)
 	}
-	return &Four{
+	// Use a bitboard when the grid fits, otherwise fall back to scanning Content.
+	board, _ := NewBitboard(columns, rows, nWin)
+	z := zobristFor(columns, rows, nWin, nPlayers)
+	f := &Four{
 		Content:          content,
 		NWin:             nWin,
 		NPlayers:         nPlayers,
@@ -69,13 +106,38 @@ func NewConnectFour(columns, rows, nPlayers, nWin int) (*Four, error) {
 		CurPlayer:        AvailablePlayers[0],
 		Players:          map[State]string{},
 		GridState:        Empty,
-		ActivityChan:     make(chan State, 1e3), // Arbitrary large size.
-	}, nil
+		Rules:            Classic{},
+		board:            board,
+		zobrist:          z,
+		hash:             z.base,
+	}
+	for _, opt := range opts {
+		if err := opt(f); err != nil {
+			return nil, err
+		}
+	}
+	if err := f.checkRanking(); err != nil {
+		return nil, err
+	}
+	f.Variant = f.Rules.Name()
+	if err := f.Rules.Init(f); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s game", f.Variant)
+	}
+	f.initWindows()
+	// Blockers may leave nowhere to play.
+	if len(f.Rules.LegalMoves(f)) == 0 {
+		return nil, errors.Wrap(ErrNoMove, "grid blocked")
+	}
+	return f, nil
 }
 
 // Reset restarts the game.
 func (f *Four) Reset() (*Four, error) {
-	return NewConnectFour(f.Columns, f.Rows, f.NPlayers, f.NWin)
+	opts := []Option{WithRules(f.Rules), WithBlockers(f.Blockers...), WithTeams(f.MixedTeams, f.Teams...)}
+	if f.Ranking {
+		opts = append(opts, WithRanking())
+	}
+	return NewConnectFour(f.Columns, f.Rows, f.NPlayers, f.NWin, opts...)
 }
 
 // State return the state of the grid at the x/y position.
@@ -83,8 +145,35 @@ func (f *Four) State(x, y int) State {
 	return f.Content[x][y]
 }
 
+// Set updates the given cell in the grid, its bitboard and the hash.
+// Meant for Rules implementations, players move with Play.
+// Blockers are fixed for the whole game and left out of the hash.
+func (f *Four) Set(x, y int, s State) {
+	keys := &f.zobrist.cells[x*f.Columns+y]
+	old := f.Content[x][y]
+	if old != Empty && old != Blocker {
+		f.hash ^= keys[old]
+	}
+	if s != Empty && s != Blocker {
+		f.hash ^= keys[s]
+	}
+	if f.live != nil && old != Blocker && s != Blocker {
+		f.live.set(x, y, f.side(old), f.side(s))
+	}
+	f.Content[x][y] = s
+	if f.board != nil {
+		f.board.Set(x, y, s)
+	}
+}
+
 // ColumnCount returns the count of occupied cells in the requested column.
 func (f *Four) ColumnCount(col int) int {
+	if f.board != nil {
+		if h := f.board.Height(col); h != 0 {
+			return f.Rows - h
+		}
+		return 0
+	}
 	for i, elem := range f.Content {
 		if elem[col] != Empty {
 			return i
@@ -93,45 +182,144 @@ func (f *Four) ColumnCount(col int) int {
 	return 0
 }
 
-// ValidateMove checks if the given move is valid.
+// Landing returns the row where a piece dropped in the given column would land.
+// Returns -1 if the column is full.
+func (f *Four) Landing(col int) int {
+	if f.board != nil {
+		return f.board.Landing(col)
+	}
+	// Make it fall as long as we are empty.
+	j := 0
+	for ; j < len(f.Content); j++ {
+		if f.Content[j][col] != Empty {
+			break
+		}
+	}
+	return j - 1
+}
+
+// ValidateMove checks if the given player can drop a piece in the given column.
 func (f *Four) ValidateMove(player State, col int) error {
-	// Validate move.
+	return f.Validate(Move{Player: player, Column: col})
+}
+
+// Validate checks if the given move is valid.
+func (f *Four) Validate(m Move) error {
+	// No move once the game is won or drawn.
+	if f.GridState != Empty {
+		return ErrGameOver
+	}
 	// Check if expected player.
-	if player != f.CurPlayer {
+	if m.Player != f.CurPlayer {
 		return errors.New("invalid move, not player's turn")
 	}
-	// Les than 0, too big or column already full.
-	if col < 0 || col >= len(f.Content[0]) || f.Content[0][col] != Empty {
-		return errors.Wrapf(ErrInvalidMove, "%d is an invalid move for player %d (%s)", col, f.CurPlayer, f.CurPlayer)
+	return f.Rules.Validate(f, m)
+}
+
+// PlayerMove drops a piece in the given column for the given player.
+// Returns the resulting grid state and, upon victory, the winning lines.
+func (f *Four) PlayerMove(player State, col int) (State, []Line, error) {
+	return f.Play(Move{Player: player, Column: col})
+}
+
+// Play plays the given move.
+// Returns the resulting grid state and, upon victory, the winning lines.
+func (f *Four) Play(m Move) (State, []Line, error) {
+	if err := f.Validate(m); err != nil {
+		return Empty, nil, err
 	}
-	return nil
+	// A new move discards the taken back ones.
+	f.redo = f.redo[:0]
+
+	m, lines := f.apply(m)
+	return m.GridState, lines, nil
 }
 
-// PlayerMove plays a move for the given player.
-func (f *Four) PlayerMove(player State, col int) (State, error) {
-	if err := f.ValidateMove(player, col); err != nil {
-		return Empty, err
+// apply plays the move, records it in the history and checks for the end of the game.
+// The move is expected to be valid.
+func (f *Four) apply(m Move) (Move, []Line) {
+	m = f.Rules.Apply(f, m)
+	m.Time = time.Now()
+	m.placements = len(f.Placements)
+	f.record(m)
+
+	ret, lines := f.terminal(m)
+	if f.Ranking {
+		ret = f.rank(ret)
 	}
-	// Make it fall as long as we are empty.
-	j := 0
-	for ; j < len(f.Content); j++ {
-		if f.Content[j][col] != Empty {
+	f.History[len(f.History)-1].GridState = ret
+	f.conclude(ret, lines)
+	return f.History[len(f.History)-1], lines
+}
+
+// terminal returns the state of the game after the given move, see Rules.Terminal.
+// Lines mixing the colors of a team are credited to its first player, as Compute does.
+func (f *Four) terminal(m Move) (State, []Line) {
+	ret, lines := f.Rules.Terminal(f, m)
+	if ret != Stale {
+		ret = f.side(ret)
+	}
+	return ret, lines
+}
+
+// record appends the given move to the history and gives the turn to the next player.
+func (f *Four) record(m Move) {
+	f.advance()
+	m.hash = f.Hash()
+	f.History = append(f.History, m)
+}
+
+// advance gives the turn to the next player, skipping the ranked ones. See WithRanking.
+func (f *Four) advance() {
+	for {
+		f.CurPlayerIdx++
+		f.CurPlayerIdx %= f.NPlayers
+		if f.Rank(f.AvailablePlayers[f.CurPlayerIdx]) == 0 {
 			break
 		}
 	}
-	// Set the state in the internal grid.
-	f.Content[j-1][col] = f.CurPlayer
-
-	// Update Current Player.
-	f.CurPlayerIdx++
-	f.CurPlayerIdx %= f.NPlayers
 	f.CurPlayer = f.AvailablePlayers[f.CurPlayerIdx]
+}
 
-	return f.Compute(), nil
+// conclude sets and notifies the grid state.
+// lines are the winning lines of the last move, the grid is scanned when unknown.
+func (f *Four) conclude(ret State, lines []Line) {
+	f.GridState = ret
+	f.WinningLines = nil
+	if ret != Empty && ret != Stale {
+		// In ranking mode, the last move may have ranked another player than the winner.
+		if f.Ranking || len(lines) == 0 {
+			lines = f.winningLines()
+		}
+		f.WinningLines = lines
+	}
+	f.notify(ret)
 }
 
-// compute goes point by point and tries the nWin in every directions.
+// compute checks if one of the players has nWin in a row.
+// Uses the bitboard when available, otherwise scans the grid.
 func (f *Four) compute() State {
+	if f.board != nil && !f.wrap {
+		if f.MixedTeams {
+			for _, team := range f.Teams {
+				if f.board.Wins(team...) {
+					return team[0]
+				}
+			}
+			return Empty
+		}
+		for _, p := range f.AvailablePlayers {
+			if f.board.Wins(p) {
+				return p
+			}
+		}
+		return Empty
+	}
+	return f.scan()
+}
+
+// scan goes point by point and tries the nWin in every directions.
+func (f *Four) scan() State {
 	for i := 0; i < len(f.Content); i++ {
 		for j := 0; j < len(f.Content[i]); j++ {
 			// Check Columns.
@@ -167,22 +355,24 @@ func (f *Four) checkDirection(x, y, xDir, yDir int) State {
 	}
 
 	// Initialize previous state as the original.
-	prev := f.Content[x][y]
+	// Teammates count as one when lines may mix their colors.
+	prev := f.side(f.Content[x][y])
 
 	// Check f.nWin cells.
 	for i := 0; i < f.NWin; i++ {
 		// Check for boundaries, if outside, then return.
+		// On cylinders, columns wrap around instead.
 		if x+i*xDir < 0 || x+i*xDir >= len(f.Content) ||
-			y+i*yDir < 0 || y+i*yDir >= len(f.Content[0]) {
+			f.wrapCol(y+i*yDir) < 0 || f.wrapCol(y+i*yDir) >= len(f.Content[0]) {
 			return Empty
 		}
 
 		// Check the cell in the requested direciton.
-		cur := f.Content[x+i*xDir][y+i*yDir]
+		cur := f.side(f.Content[x+i*xDir][f.wrapCol(y+i*yDir)])
 		if prev != cur {
 			playerState[prev] = 0
 			playerState[cur] = 1
-		} else if playerState[cur]++; cur != Empty && playerState[cur] >= f.NWin {
+		} else if playerState[cur]++; cur != Empty && cur != Blocker && playerState[cur] >= f.NWin {
 			return cur
 		}
 		prev = cur
@@ -190,47 +380,42 @@ func (f *Four) checkDirection(x, y, xDir, yDir int) State {
 	return Empty
 }
 
-// notify sends the given state to the activityChan.
-// if state is not Empty, close the channel.
-func (f *Four) notify(s State) {
-	f.Lock()
-	defer f.Unlock()
-
-	if f.ActivityChan == nil {
-		return
-	}
-	select {
-	case f.ActivityChan <- s:
-	default:
-	}
-	if s != Empty {
-		close(f.ActivityChan)
-		f.ActivityChan = nil
+// Compute processes the current state and checks if
+// one of the player won. The result is set as the state of the game, see Scan.
+// Once the game is over, its state is returned as is: the rules may have ended
+// it in ways the grid does not tell, early draws, repetitions or pops
+// completing several lines. In ranking mode, the lines of the ranked players
+// stay on the grid and only the history tells the state of the game.
+func (f *Four) Compute() State {
+	if f.Ranking || f.GridState != Empty {
+		return f.GridState
 	}
+	ret, lines := f.Scan()
+	f.conclude(ret, lines)
+	return ret
 }
 
-// Compute processes the current state and checks if
-// one of the player won.
-func (f *Four) Compute() State {
-	// Check all directions.
+// Scan rescans the whole grid and returns the state it finds and, upon
+// victory, the lines of the winner. The game is left untouched.
+// Play only looks at the last move, Scan is kept as a validation path.
+// Early draws, when no line can be completed anymore, are left to the
+// rules, see Four.Live.
+func (f *Four) Scan() (State, []Line) {
+	// Check all directions, then if we are in a stale situation.
 	if ret := f.compute(); ret != Empty {
-		f.GridState = ret
-		f.notify(ret)
-		return ret
+		return ret, f.linesOf(ret)
 	}
-
-	// Check if we are in a stale situation.
-	if f.checkStale() {
-		f.GridState = Stale
-		f.notify(Stale)
-		return Stale
+	if len(f.Rules.LegalMoves(f)) == 0 {
+		return Stale, nil
 	}
-	f.notify(Empty)
-	return Empty
+	return Empty, nil
 }
 
-// checkStale checks if the grid is complete and the game is finished.
-func (f *Four) checkStale() bool {
+// Full returns true if the top row of the grid is complete.
+func (f *Four) Full() bool {
+	if f.board != nil {
+		return f.board.Full()
+	}
 	for _, state := range f.Content[0] {
 		if state == Empty {
 			return false
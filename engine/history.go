@@ -0,0 +1,53 @@
+package engine
+
+import "time"
+
+// Move is an entry of the game history.
+type Move struct {
+	Player    State     `json:"player"`
+	Column    int       `json:"column"`
+	Row       int       `json:"row"`           // Row where the piece landed.
+	GridState State     `json:"grid_state"`    // State of the grid after the move.
+	Time      time.Time `json:"time"`          // When the move was played.
+	Pop       bool      `json:"pop,omitempty"` // PopOut: the bottom piece was removed instead of dropping one.
+
+	hash       uint64 // Hash of the resulting position.
+	placements int    // Count of ranked players before the move, see WithRanking.
+}
+
+// Undo takes back the last move and gives the turn back to its player.
+// The move can be replayed with Redo until a new move is played.
+func (f *Four) Undo() (Move, error) {
+	if len(f.History) == 0 {
+		return Move{}, ErrNoMove
+	}
+	m := f.History[len(f.History)-1]
+	f.History = f.History[:len(f.History)-1]
+	f.redo = append(f.redo, m)
+
+	f.Rules.Revert(f, m)
+	f.setTurn(m.Player)
+	f.Placements = f.Placements[:m.placements]
+
+	// The game was still running before the move, otherwise it could not have been played.
+	f.GridState = Empty
+	f.WinningLines = nil
+	f.notify(Empty)
+	return m, nil
+}
+
+// Redo replays the last move taken back by Undo.
+// Returns the replayed move and, upon victory, the winning lines.
+func (f *Four) Redo() (Move, []Line, error) {
+	if len(f.redo) == 0 {
+		return Move{}, nil, ErrNoMove
+	}
+	m := f.redo[len(f.redo)-1]
+	if err := f.Validate(m); err != nil {
+		return Move{}, nil, err
+	}
+	f.redo = f.redo[:len(f.redo)-1]
+
+	m, lines := f.apply(m)
+	return m, lines, nil
+}
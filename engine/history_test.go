@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestUndoRedo plays the last move of fixed games, takes it back and replays
+// it: the undo restores the game as it was before the move, the redo as it was
+// after it.
+func TestUndoRedo(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		cols, rows int
+		nPlayers   int
+		nWin       int
+		opts       []Option
+		moves      []int // Columns played, the last one is taken back.
+		state      State // Grid state after the last move.
+		placements []State
+	}{
+		{name: "running", cols: 7, rows: 6, nPlayers: 2, nWin: 4, moves: []int{3, 3, 4}, state: Empty},
+		{name: "win", cols: 7, rows: 6, nPlayers: 2, nWin: 4, moves: []int{0, 1, 0, 1, 0, 1, 0}, state: Red},
+		{name: "stale", cols: 3, rows: 3, nPlayers: 2, nWin: 3, moves: []int{0, 0, 0, 1, 2, 1, 2, 2, 1}, state: Stale},
+		{name: "early draw", cols: 3, rows: 3, nPlayers: 2, nWin: 3, moves: []int{0, 0, 0, 1, 1, 2, 2, 2}, state: Stale},
+		{
+			name: "ranking", cols: 7, rows: 6, nPlayers: 3, nWin: 4, opts: []Option{WithRanking()},
+			moves: []int{0, 1, 2, 0, 1, 2, 0, 1, 2, 0}, state: Empty, placements: []State{Red},
+		},
+	} {
+		f, err := NewConnectFour(tc.cols, tc.rows, tc.nPlayers, tc.nWin, tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		last := tc.moves[len(tc.moves)-1]
+		for _, col := range tc.moves[:len(tc.moves)-1] {
+			if _, _, err := f.PlayerMove(f.CurPlayer, col); err != nil {
+				t.Fatal(err)
+			}
+		}
+		player := f.CurPlayer
+		before := takeSnapshot(f)
+		if _, _, err := f.PlayerMove(player, last); err != nil {
+			t.Fatal(err)
+		}
+		after := takeSnapshot(f)
+		if f.GridState != tc.state || !reflect.DeepEqual(f.Placements, tc.placements) {
+			t.Fatalf("[%s] Unexpected end of the move.\nExpected:\t%d %v\nGot:\t\t%d %v", tc.name, tc.state, tc.placements, f.GridState, f.Placements)
+		}
+		if (tc.state != Empty && tc.state != Stale) != (len(f.WinningLines) != 0) {
+			t.Fatalf("[%s] Unexpected winning lines: %v", tc.name, f.WinningLines)
+		}
+
+		m, err := f.Undo()
+		if err != nil {
+			t.Fatalf("[%s] Unexpected error taking back the move: %s", tc.name, err)
+		}
+		if m.Player != player || m.Column != last || m.GridState != tc.state {
+			t.Fatalf("[%s] Unexpected move taken back: %+v", tc.name, m)
+		}
+		got := takeSnapshot(f)
+		if !reflect.DeepEqual(got.redo, []Move{after.history[len(after.history)-1]}) {
+			t.Fatalf("[%s] Unexpected redo stack after undo: %+v", tc.name, got.redo)
+		}
+		got.redo = before.redo
+		if !reflect.DeepEqual(got, before) {
+			t.Fatalf("[%s] Unexpected game after undo.\nExpected:\tplayer %d, state %d, lines %v, placements %v\nGot:\t\tplayer %d, state %d, lines %v, placements %v",
+				tc.name, before.curPlayer, before.gridState, before.lines, before.placements, got.curPlayer, got.gridState, got.lines, got.placements)
+		}
+
+		if _, _, err := f.Redo(); err != nil {
+			t.Fatalf("[%s] Unexpected error replaying the move: %s", tc.name, err)
+		}
+		// Only the time of the replayed move differs.
+		got = takeSnapshot(f)
+		got.history[len(got.history)-1].Time = after.history[len(after.history)-1].Time
+		if !reflect.DeepEqual(got, after) {
+			t.Fatalf("[%s] Unexpected game after redo.\nExpected:\tplayer %d, state %d, lines %v, placements %v\nGot:\t\tplayer %d, state %d, lines %v, placements %v",
+				tc.name, after.curPlayer, after.gridState, after.lines, after.placements, got.curPlayer, got.gridState, got.lines, got.placements)
+		}
+		if _, _, err := f.Redo(); err != ErrNoMove {
+			t.Fatalf("[%s] Unexpected error replaying with nothing taken back.\nExpected:\t%v\nGot:\t\t%v", tc.name, ErrNoMove, err)
+		}
+	}
+}
+
+// TestRedoDiscarded checks a new move discards the moves taken back, and
+// the moves taken back can not be replayed once the game is over.
+func TestRedoDiscarded(t *testing.T) {
+	f, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []int{0, 1, 0, 1, 0} {
+		if _, _, err := f.PlayerMove(f.CurPlayer, col); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := f.Undo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := f.PlayerMove(Yellow, 6); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.Redo(); err != ErrNoMove {
+		t.Fatalf("Unexpected error replaying after a new move.\nExpected:\t%v\nGot:\t\t%v", ErrNoMove, err)
+	}
+	if len(f.History) != 4 || f.History[3].Column != 6 {
+		t.Fatalf("Unexpected history after a new move: %+v", f.History)
+	}
+
+	// A game ended outside of the moves, as by Compute, keeps its end.
+	if _, err := f.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	f.conclude(Stale, nil)
+	if _, _, err := f.Redo(); err != ErrGameOver {
+		t.Fatalf("Unexpected error replaying after the end of the game.\nExpected:\t%v\nGot:\t\t%v", ErrGameOver, err)
+	}
+	if len(f.History) != 3 || f.GridState != Stale {
+		t.Fatalf("Unexpected game after replaying past its end: %d moves, state %d", len(f.History), f.GridState)
+	}
+
+	g, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Undo(); err != ErrNoMove {
+		t.Fatalf("Unexpected error taking back from an empty game.\nExpected:\t%v\nGot:\t\t%v", ErrNoMove, err)
+	}
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"bufio"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Layout is the shape of a grid: its size and the cells blocked before the game starts.
+type Layout struct {
+	Columns  int
+	Rows     int
+	Blockers []Cell
+}
+
+// WithBlockers sets the given cells as blockers. Pieces stack on top of
+// them and they break the lines going through them.
+func WithBlockers(cells ...Cell) Option {
+	return func(f *Four) error {
+		for _, c := range cells {
+			if !f.inside(c.Row, c.Col) {
+				return errors.Errorf("blocker %d/%d outside of the grid", c.Col, c.Row)
+			}
+			if f.Content[c.Row][c.Col] != Empty {
+				return errors.Errorf("duplicate blocker %d/%d", c.Col, c.Row)
+			}
+			f.Set(c.Row, c.Col, Blocker)
+		}
+		if len(cells) != 0 {
+			f.Blockers = append([]Cell(nil), cells...)
+		}
+		return nil
+	}
+}
+
+// ParseLayout reads a layout with one line per row, from the top, where '.'
+// is an empty cell and '#' a blocker. Blank lines are ignored. E.g. a 5x3
+// grid with a pillar in the middle:
+//
+//	.....
+//	..#..
+//	..#..
+func ParseLayout(r io.Reader) (*Layout, error) {
+	l := &Layout{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if l.Rows == 0 {
+			l.Columns = len(line)
+		} else if len(line) != l.Columns {
+			return nil, errors.Errorf("invalid layout, row %d has %d columns, expected %d", l.Rows+1, len(line), l.Columns)
+		}
+		for col, c := range line {
+			switch c {
+			case '.':
+			case '#':
+				l.Blockers = append(l.Blockers, Cell{Row: l.Rows, Col: col})
+			default:
+				return nil, errors.Errorf("invalid layout, unexpected %q in row %d", c, l.Rows+1)
+			}
+		}
+		l.Rows++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "error reading layout")
+	}
+	if l.Rows == 0 {
+		return nil, errors.New("invalid layout, no rows")
+	}
+	return l, nil
+}
+
+// RandomLayout returns a layout with n blockers spread over the grid.
+// The same seed always yields the same layout.
+func RandomLayout(columns, rows, n int, seed int64) (*Layout, error) {
+	if n < 0 || n >= columns*rows {
+		return nil, errors.Errorf("invalid blocker count: %d for a %dx%d grid", n, columns, rows)
+	}
+	cells := rand.New(rand.NewSource(seed)).Perm(columns * rows)[:n]
+	sort.Ints(cells)
+
+	l := &Layout{Columns: columns, Rows: rows}
+	for _, c := range cells {
+		l.Blockers = append(l.Blockers, Cell{Row: c / columns, Col: c % columns})
+	}
+	return l, nil
+}
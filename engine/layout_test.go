@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRandomLayout(t *testing.T) {
+	for _, n := range []int{-1, DefaultCols * DefaultRows, DefaultCols*DefaultRows + 1} {
+		if _, err := RandomLayout(DefaultCols, DefaultRows, n, 1); err == nil {
+			t.Errorf("Unexpected success with %d blockers", n)
+		}
+	}
+
+	l, err := RandomLayout(DefaultCols, DefaultRows, 6, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Columns != DefaultCols || l.Rows != DefaultRows || len(l.Blockers) != 6 {
+		t.Fatalf("Unexpected layout: %dx%d with %d blockers", l.Columns, l.Rows, len(l.Blockers))
+	}
+	seen := map[Cell]bool{}
+	for _, c := range l.Blockers {
+		if c.Row < 0 || c.Row >= l.Rows || c.Col < 0 || c.Col >= l.Columns || seen[c] {
+			t.Fatalf("Unexpected blocker %v in %v", c, l.Blockers)
+		}
+		seen[c] = true
+	}
+
+	// The same seed gives the same layout, another one a different layout.
+	again, err := RandomLayout(DefaultCols, DefaultRows, 6, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, l) {
+		t.Fatalf("Unexpected layout with the same seed.\nExpected:\t%v\nGot:\t\t%v", l.Blockers, again.Blockers)
+	}
+	other, err := RandomLayout(DefaultCols, DefaultRows, 6, 43)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(other, l) {
+		t.Fatalf("Unexpected same layout with another seed: %v", l.Blockers)
+	}
+}
+
+func TestParseLayout(t *testing.T) {
+	l, err := ParseLayout(strings.NewReader("\n.....\n..#..\n\n#.#..\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &Layout{Columns: 5, Rows: 3, Blockers: []Cell{{Row: 1, Col: 2}, {Row: 2, Col: 0}, {Row: 2, Col: 2}}}
+	if !reflect.DeepEqual(l, expect) {
+		t.Fatalf("Unexpected layout.\nExpected:\t%+v\nGot:\t\t%+v", expect, l)
+	}
+
+	for _, s := range []string{"", "\n\n", ".....\n...", "..x.."} {
+		if _, err := ParseLayout(strings.NewReader(s)); err == nil {
+			t.Errorf("Unexpected success parsing %q", s)
+		}
+	}
+}
+
+// TestBlockers checks the pieces stack on the blockers, which break the lines.
+func TestBlockers(t *testing.T) {
+	for _, cells := range [][]Cell{
+		{{Row: DefaultRows, Col: 0}},
+		{{Row: 0, Col: -1}},
+		{{Row: 5, Col: 3}, {Row: 5, Col: 3}},
+	} {
+		if _, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin, WithBlockers(cells...)); err == nil {
+			t.Errorf("Unexpected success with the blockers %v", cells)
+		}
+	}
+
+	f, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin, WithBlockers(Cell{Row: 5, Col: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Red fills the bottom row around the blocker, Yellow stacks on it.
+	for _, col := range []int{0, 2, 1, 2, 3, 2, 4} {
+		if _, _, err := f.PlayerMove(f.CurPlayer, col); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f.State(4, 2) != Yellow || f.State(5, 2) != Blocker {
+		t.Fatalf("Unexpected column on the blocker: %d, %d", f.State(4, 2), f.State(5, 2))
+	}
+	if f.GridState != Empty {
+		t.Fatalf("Unexpected end of the game through a blocker: %d", f.GridState)
+	}
+	for _, col := range []int{0, 5, 1, 6} {
+		if _, _, err := f.PlayerMove(f.CurPlayer, col); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f.GridState != Red || len(f.WinningLines) != 1 || len(f.WinningLines[0]) != 4 || f.WinningLines[0][0].Col != 3 {
+		t.Fatalf("Unexpected end of the game.\nExpected:\t%d, right of the blocker\nGot:\t\t%d, %v", Red, f.GridState, f.WinningLines)
+	}
+}
@@ -0,0 +1,391 @@
+package engine
+
+import "sync"
+
+// Cell is a position in the grid. Row 0 is the top of the grid.
+type Cell struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+}
+
+// Line is a run of aligned cells.
+type Line []Cell
+
+// directions lists the row/col steps of the four line directions.
+var directions = [4][2]int{
+	{1, 0},  // Vertical.
+	{0, 1},  // Horizontal.
+	{1, 1},  // Diagonal.
+	{-1, 1}, // Anti-diagonal.
+}
+
+// inside returns true if x/y is within the grid.
+func (f *Four) inside(x, y int) bool {
+	return x >= 0 && x < f.Rows && y >= 0 && y < f.Columns
+}
+
+// wrapCol returns the given column wrapped around the grid on cylinders, unchanged otherwise.
+func (f *Four) wrapCol(y int) int {
+	if !f.wrap {
+		return y
+	}
+	return (y%f.Columns + f.Columns) % f.Columns
+}
+
+// Wraps returns true if the lines wrap from the rightmost column back to the leftmost.
+func (f *Four) Wraps() bool {
+	return f.wrap
+}
+
+// run counts the consecutive cells matching s from x/y (excluded) in the given direction.
+// s is a side, see Four.side.
+func (f *Four) run(x, y, xDir, yDir int, s State) int {
+	n := 0
+	for x, y = x+xDir, f.wrapCol(y+yDir); f.inside(x, y) && f.side(f.Content[x][y]) == s; x, y = x+xDir, f.wrapCol(y+yDir) {
+		// Stop once the whole row is covered, a wrapping run would go on forever.
+		if n++; xDir == 0 && n == f.Columns-1 {
+			break
+		}
+	}
+	return n
+}
+
+// LinesFrom scans outward from x/y in the four directions and returns
+// every run of at least nWin cells going through it.
+func (f *Four) LinesFrom(x, y int) []Line {
+	s := f.side(f.Content[x][y])
+	if s == Empty || s == Blocker {
+		return nil
+	}
+	// The bitboard tells right away if there is a line to collect.
+	if f.board != nil && !f.wrap {
+		players := []State{s}
+		if f.MixedTeams {
+			players = f.Team(s)
+		}
+		if !f.board.LineThrough(x, y, players...) {
+			return nil
+		}
+	}
+	var lines []Line
+	for _, d := range directions {
+		back := f.run(x, y, -d[0], -d[1], s)
+		forth := f.run(x, y, d[0], d[1], s)
+		if back+forth+1 > f.Columns && d[0] == 0 {
+			// Full row on a cylinder, do not count the cells twice.
+			forth = f.Columns - 1 - back
+		}
+		if back+forth+1 < f.NWin {
+			continue
+		}
+		line := make(Line, 0, back+forth+1)
+		for i := -back; i <= forth; i++ {
+			line = append(line, Cell{Row: x + i*d[0], Col: f.wrapCol(y + i*d[1])})
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Windows returns every run of nWin cells a line could occupy.
+// The list is built on the first call. The result is shared, it must not be modified.
+func (f *Four) Windows() []Line {
+	w := f.windows
+	w.once.Do(func() {
+		for i, k := range w.kinds {
+			if k == noWindow {
+				continue
+			}
+			x, y, d := w.start(i)
+			line := make(Line, w.nWin)
+			for j := range line {
+				line[j] = Cell{Row: x + j*d[0], Col: w.wrapCol(y + j*d[1])}
+			}
+			w.lines = append(w.lines, line)
+		}
+	})
+	return w.lines
+}
+
+// Window kinds, see windowIndex.
+const (
+	noWindow   = iota // Outside of the grid, blocked or the same as another one.
+	deadWindow        // Holds a cell no piece can ever be played on.
+	liveWindow
+)
+
+// windowIndex numbers the windows of a grid by their first cell and their
+// direction: row*columns*4 + col*4 + direction. Built once the grid, its
+// blockers and its rules are set, then shared by the clones of the game.
+type windowIndex struct {
+	columns int
+	rows    int
+	nWin    int
+	wrap    bool
+	kinds   []uint8 // Kind of each window. Read only.
+
+	once  sync.Once
+	lines []Line // See Four.Windows.
+}
+
+// start returns the first cell and the direction of the given window.
+func (w *windowIndex) start(i int) (int, int, [2]int) {
+	return i / 4 / w.columns, i / 4 % w.columns, directions[i%4]
+}
+
+// wrapCol returns the given column wrapped around the grid on cylinders, see Four.wrapCol.
+func (w *windowIndex) wrapCol(y int) int {
+	if !w.wrap {
+		return y
+	}
+	return (y%w.columns + w.columns) % w.columns
+}
+
+// holding calls fn with each window holding the cell x/y, whatever its kind.
+func (w *windowIndex) holding(x, y int, fn func(i int)) {
+	for d, dir := range directions {
+		for j := 0; j < w.nWin; j++ {
+			// Going back from the cell, once out of the grid, we stay out.
+			sx, sy := x-j*dir[0], w.wrapCol(y-j*dir[1])
+			if sx < 0 || sx >= w.rows || sy < 0 || sy >= w.columns {
+				break
+			}
+			fn((sx*w.columns+sy)*4 + d)
+		}
+	}
+}
+
+// initWindows indexes the windows of the grid and starts counting their pieces.
+// Called once the grid, its blockers and its rules are set.
+func (f *Four) initWindows() {
+	w := &windowIndex{
+		columns: f.Columns,
+		rows:    f.Rows,
+		nWin:    f.NWin,
+		wrap:    f.wrap,
+		kinds:   make([]uint8, f.Rows*f.Columns*4),
+	}
+	l := &liveness{
+		windows: w,
+		count:   make([][Stale]uint8, len(w.kinds)),
+		sides:   make([]uint8, len(w.kinds)),
+	}
+	for i := range w.kinds {
+		x, y, d := w.start(i)
+		if !f.inside(x+(f.NWin-1)*d[0], f.wrapCol(y+(f.NWin-1)*d[1])) {
+			continue
+		}
+		// On a cylinder as wide as nWin, every rotation of a row is the same window.
+		if f.wrap && d[0] == 0 && f.NWin == f.Columns && y != 0 {
+			continue
+		}
+		w.kinds[i] = liveWindow
+	}
+	if len(f.Blockers) != 0 {
+		// Blockers break the lines, no one can fill their windows.
+		for _, c := range f.Blockers {
+			w.holding(c.Row, c.Col, func(i int) { w.kinds[i] = noWindow })
+		}
+		// With gravity, the cells below a blocker are out of reach for good.
+		for y := 0; y < f.Columns && !f.FreePlacement(); y++ {
+			below := false
+			for x := 0; x < f.Rows; x++ {
+				if f.Content[x][y] == Blocker {
+					below = true
+					continue
+				}
+				if below {
+					w.holding(x, y, func(i int) {
+						if w.kinds[i] == liveWindow {
+							w.kinds[i] = deadWindow
+						}
+					})
+				}
+			}
+		}
+	}
+	for _, k := range w.kinds {
+		if k == liveWindow {
+			l.empty++
+		}
+	}
+	f.windows, f.live = w, l
+	for x := range f.Content {
+		for y, s := range f.Content[x] {
+			if s != Empty && s != Blocker {
+				l.set(x, y, Empty, f.side(s))
+			}
+		}
+	}
+}
+
+// open returns true if a piece can still be played at x/y. With gravity,
+// a cell below the landing row of its column is out of reach for good.
+func (f *Four) open(x, y int) bool {
+	return f.Content[x][y] == Empty && (f.FreePlacement() || f.Landing(y) >= x)
+}
+
+// liveness counts the pieces of each window within reach, so the windows
+// still open to a side are known without scanning the grid. See Four.Live.
+// Windows are addressed by their index, see windowIndex.
+type liveness struct {
+	windows *windowIndex   // Read only.
+	count   [][Stale]uint8 // Pieces of each side in each window.
+	sides   []uint8        // Mask of the sides in each window, bit n for the side n.
+	empty   int            // Windows without pieces.
+	single  [Stale]int     // Windows holding the pieces of a single side, by side.
+}
+
+// clone returns a copy of l sharing the read only parts.
+func (l *liveness) clone() *liveness {
+	c := *l
+	c.count = append([][Stale]uint8(nil), l.count...)
+	c.sides = append([]uint8(nil), l.sides...)
+	return &c
+}
+
+// set replaces the side old by the side s in the windows of the cell x/y.
+// Sides are players, Empty or, when lines may mix the team colors, teams. See Four.side.
+func (l *liveness) set(x, y int, old, s State) {
+	if old == s {
+		return
+	}
+	l.windows.holding(x, y, func(i int) {
+		if l.windows.kinds[i] != liveWindow {
+			return
+		}
+		m := l.sides[i]
+		if old != Empty {
+			if l.count[i][old]--; l.count[i][old] == 0 {
+				m &^= 1 << uint(old)
+			}
+		}
+		if s != Empty {
+			if l.count[i][s]++; l.count[i][s] == 1 {
+				m |= 1 << uint(s)
+			}
+		}
+		if m != l.sides[i] {
+			l.classify(l.sides[i], -1)
+			l.classify(m, 1)
+			l.sides[i] = m
+		}
+	})
+}
+
+// sideOf holds the side of each mask holding a single side, see liveness.sides.
+var sideOf = func() (t [1 << Stale]State) {
+	for s := uint(0); s < Stale; s++ {
+		t[1<<s] = State(s)
+	}
+	return t
+}()
+
+// classify adds n to the counter of the kind of window with the given sides:
+// empty, single side or, uncounted, several sides.
+func (l *liveness) classify(m uint8, n int) {
+	switch {
+	case m == 0:
+		l.empty += n
+	case m&(m-1) == 0:
+		l.single[sideOf[m]] += n
+	}
+}
+
+// LiveWindows returns how many windows the given player can still complete.
+func (f *Four) LiveWindows(p State) int {
+	return f.live.empty + f.live.single[f.side(p)]
+}
+
+// Live returns true if a player still in the game can complete a window.
+// Once false, the game can only end in a draw.
+func (f *Four) Live() bool {
+	if f.live.empty != 0 {
+		return true
+	}
+	for s, n := range f.live.single {
+		if n != 0 && f.Rank(State(s)) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// blocked returns true if one of the cells of l is a blocker.
+func (f *Four) blocked(l Line) bool {
+	for _, c := range l {
+		if f.Content[c.Row][c.Col] == Blocker {
+			return true
+		}
+	}
+	return false
+}
+
+// winningLines returns every line of the winner on the grid, nil unless the game is won.
+// In ranking mode, the winner is the first ranked player.
+func (f *Four) winningLines() []Line {
+	if f.GridState == Empty || f.GridState == Stale {
+		return nil
+	}
+	return f.linesOf(f.GridState)
+}
+
+// linesOf returns every line of the side of the given player on the grid.
+func (f *Four) linesOf(p State) []Line {
+	s := f.side(p)
+	var lines []Line
+	for x := 0; x < f.Rows; x++ {
+		for y := 0; y < f.Columns; y++ {
+			if f.side(f.Content[x][y]) != s {
+				continue
+			}
+			for _, l := range f.LinesFrom(x, y) {
+				// Each line is found from each of its cells.
+				if !hasLine(lines, l) {
+					lines = append(lines, l)
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// sameCells returns true if both lines hold the same cells, in any order.
+// A full row of a cylinder is found with a different start from each cell.
+func sameCells(a, b Line) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	cells := make(map[Cell]bool, len(a))
+	for _, c := range a {
+		cells[c] = true
+	}
+	for _, c := range b {
+		if !cells[c] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasLine returns true if lines holds l.
+func hasLine(lines []Line, l Line) bool {
+	for _, elem := range lines {
+		if sameCells(elem, l) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastLines returns the winning lines of the last move, if it won the game.
+func (f *Four) lastLines() []Line {
+	if len(f.History) == 0 || f.GridState == Empty || f.GridState == Stale {
+		return nil
+	}
+	// In ranking mode, the last move may have ranked another player than the winner.
+	if ret, lines := f.terminal(f.History[len(f.History)-1]); ret == f.GridState {
+		return lines
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// windowSide returns the side holding the pieces of the window, Empty if
+// none, Stale if it can no longer be completed. Reference for liveness.
+func windowSide(f *Four, w Line) State {
+	owner := State(Empty)
+	for _, c := range w {
+		s := f.Content[c.Row][c.Col]
+		if s == Empty {
+			if !f.open(c.Row, c.Col) {
+				return Stale
+			}
+			continue
+		}
+		if s = f.side(s); owner != Empty && s != owner {
+			return Stale
+		}
+		owner = s
+	}
+	return owner
+}
+
+// checkLive fails if the window counts differ from a scan of the grid.
+func checkLive(t *testing.T, name string, f *Four) {
+	live := false
+	for _, p := range f.AvailablePlayers {
+		expect := 0
+		for _, w := range f.Windows() {
+			if s := windowSide(f, w); s == Empty || s == f.side(p) {
+				expect++
+			}
+			if s := windowSide(f, w); s == Empty || s != Stale && f.Rank(s) == 0 {
+				live = true
+			}
+		}
+		if got := f.LiveWindows(p); got != expect {
+			t.Fatalf("[%s] Unexpected live windows of player %d in %s.\nExpected:\t%d\nGot:\t\t%d", name, p, f.Notation(), expect, got)
+		}
+	}
+	if got := f.Live(); got != live {
+		t.Fatalf("[%s] Unexpected live state of %s.\nExpected:\t%t\nGot:\t\t%t", name, f.Notation(), live, got)
+	}
+}
+
+// TestLiveWindows plays and takes back random moves and checks the window
+// counts kept along the moves against a scan of the grid.
+func TestLiveWindows(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 600; i++ {
+		var (
+			opts     []Option
+			nPlayers = 2 + rnd.Intn(3)
+			name     = []string{"classic", "popout", "free", "cylinder"}[i%4]
+		)
+		opts = append(opts, WithVariant(name))
+		if i%3 == 0 {
+			l, err := RandomLayout(DefaultCols, DefaultRows, 6, int64(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts = append(opts, WithBlockers(l.Blockers...))
+			name += " blockers"
+		}
+		switch {
+		case i%5 == 0 && nPlayers == 4:
+			opts = append(opts, WithTeams(true, []State{Red, Green}, []State{Yellow, Magenta}))
+			name += " mixed teams"
+		case i%5 == 1 && nPlayers > 2:
+			opts = append(opts, WithRanking())
+			name += " ranking"
+		}
+		f, err := NewConnectFour(DefaultCols, DefaultRows, nPlayers, DefaultNWin, opts...)
+		if err != nil {
+			continue
+		}
+		checkLive(t, name, f)
+		randomGame(t, f, rnd, 60, func() {
+			checkLive(t, name, f)
+			if rnd.Intn(4) == 0 {
+				if _, err := f.Undo(); err != nil {
+					t.Fatal(err)
+				}
+				checkLive(t, name, f)
+			}
+		})
+		if f.GridState == Stale && f.Rules.Name() != "popout" {
+			for _, p := range f.AvailablePlayers {
+				if f.Rank(p) == 0 && f.LiveWindows(p) != 0 {
+					t.Fatalf("[%s] Unexpected live windows for player %d in the draw %s", name, p, f.Notation())
+				}
+			}
+		}
+	}
+}
+
+// TestWindowsConcurrent reads the windows of a game from several goroutines, as the server does.
+func TestWindowsConcurrent(t *testing.T) {
+	f, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := len(f.Windows())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if n := len(f.Windows()); n != expect || f.LiveWindows(Red) != expect {
+				t.Errorf("Unexpected windows.\nExpected:\t%d\nGot:\t\t%d", expect, n)
+			}
+		}()
+	}
+	wg.Wait()
+	if expect != 69 {
+		t.Fatalf("Unexpected window count of the default grid.\nExpected:\t%d\nGot:\t\t%d", 69, expect)
+	}
+}
+
+// BenchmarkPlayout plays random games to the end and takes them back, as the
+// computer players do.
+func BenchmarkPlayout(b *testing.B) {
+	f, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin)
+	if err != nil {
+		b.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		randomGame(b, f, rnd, f.Columns*f.Rows, nil)
+		for len(f.History) != 0 {
+			if _, err := f.Undo(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkNewLarge creates large games, the windows are counted, not listed.
+func BenchmarkNewLarge(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewConnectFour(100, 100, DefaultNPlayers, 50); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
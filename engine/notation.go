@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Notation returns the game record: grid size, nWin and player count followed
+// by the 1 indexed columns played, e.g. "7x6w4p2:4453".
+// When the grid has more than 9 columns, the columns are comma separated.
+// Variants other than classic are named after the header and pops are
+// prefixed with 'p', e.g. "7x6w4p2/popout:44p4". Moves addressed by column
+// and row are written <col>.<row>, 1 indexed from the top, and comma
+// separated, e.g. "3x3w3p2/free:2.2,1.1". Blockers are listed last in the
+// header, after a '#', the same way, e.g. "7x6w4p2#4.6,4.5:3352". Teams
+// follow after a '@' with the team line mode, e.g. "7x6w4p4@mixed=1+3,2+4:4455".
+// The ranking mode is marked with a 'r' after the player count, e.g. "7x6w4p3r:4455".
+func (f *Four) Notation() string {
+	moves := make([]string, 0, len(f.History))
+	for _, m := range f.History {
+		move := strconv.Itoa(m.Column + 1)
+		if m.Pop {
+			move = "p" + move
+		}
+		if f.FreePlacement() {
+			move += "." + strconv.Itoa(m.Row+1)
+		}
+		moves = append(moves, move)
+	}
+	sep := ""
+	if f.Columns > 9 || f.FreePlacement() {
+		sep = ","
+	}
+	variant := ""
+	if f.Ranking {
+		variant = "r"
+	}
+	if f.Variant != DefaultVariant {
+		variant += "/" + f.Variant
+	}
+	blockers := make([]string, 0, len(f.Blockers))
+	for _, c := range f.Blockers {
+		blockers = append(blockers, strconv.Itoa(c.Col+1)+"."+strconv.Itoa(c.Row+1))
+	}
+	if len(blockers) != 0 {
+		variant += "#" + strings.Join(blockers, ",")
+	}
+	if len(f.Teams) != 0 {
+		variant += "@" + f.TeamLines() + "=" + FormatTeams(f.Teams)
+	}
+	return fmt.Sprintf("%dx%dw%dp%d%s:%s", f.Columns, f.Rows, f.NWin, f.NPlayers, variant, strings.Join(moves, sep))
+}
+
+// ParseNotation creates a new game and replays the given game record. See Notation.
+func ParseNotation(s string) (*Four, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), ":", 2)
+	if len(parts) != 2 {
+		return nil, errors.Errorf("invalid game record %q, missing ':'", s)
+	}
+	mode, teams := "", ""
+	if i := strings.Index(parts[0], "@"); i != -1 {
+		fields := strings.SplitN(parts[0][i+1:], "=", 2)
+		if len(fields) != 2 || fields[0] != TeamLinesMixed && fields[0] != TeamLinesColor {
+			return nil, errors.Errorf("invalid game record, bad teams %q", parts[0][i+1:])
+		}
+		mode, teams = fields[0], fields[1]
+		parts[0] = parts[0][:i]
+	}
+	var blockers []Cell
+	if i := strings.Index(parts[0], "#"); i != -1 {
+		for _, b := range strings.Split(parts[0][i+1:], ",") {
+			var col, row int
+			if _, err := fmt.Sscanf(b, "%d.%d", &col, &row); err != nil || fmt.Sprintf("%d.%d", col, row) != b {
+				return nil, errors.Errorf("invalid game record, bad blocker %q", b)
+			}
+			blockers = append(blockers, Cell{Row: row - 1, Col: col - 1})
+		}
+		parts[0] = parts[0][:i]
+	}
+	variant := DefaultVariant
+	if i := strings.Index(parts[0], "/"); i != -1 {
+		parts[0], variant = parts[0][:i], parts[0][i+1:]
+	}
+
+	ranking := strings.HasSuffix(parts[0], "r")
+	if ranking {
+		parts[0] = parts[0][:len(parts[0])-1]
+	}
+
+	var columns, rows, nWin, nPlayers int
+	if _, err := fmt.Sscanf(parts[0], "%dx%dw%dp%d", &columns, &rows, &nWin, &nPlayers); err != nil ||
+		fmt.Sprintf("%dx%dw%dp%d", columns, rows, nWin, nPlayers) != parts[0] {
+		return nil, errors.Errorf("invalid game record header %q, expected <cols>x<rows>w<nwin>p<nplayers>", parts[0])
+	}
+	t, err := ParseTeams(teams, nPlayers)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid game record")
+	}
+	opts := []Option{WithVariant(variant), WithBlockers(blockers...), WithTeams(mode == TeamLinesMixed, t...)}
+	if ranking {
+		opts = append(opts, WithRanking())
+	}
+	f, err := NewConnectFour(columns, rows, nPlayers, nWin, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid game record")
+	}
+
+	var moves []string
+	if columns > 9 || f.FreePlacement() {
+		if parts[1] != "" {
+			moves = strings.Split(parts[1], ",")
+		}
+	} else {
+		for i := 0; i < len(parts[1]); i++ {
+			// Keep the pop prefix with its column.
+			j := i
+			if parts[1][i] == 'p' && i+1 < len(parts[1]) {
+				i++
+			}
+			moves = append(moves, parts[1][j:i+1])
+		}
+	}
+	for i, m := range moves {
+		if f.GridState != Empty {
+			return nil, errors.Errorf("invalid game record, game finished before move %d", i+1)
+		}
+		move := Move{Player: f.CurPlayer, Pop: strings.HasPrefix(m, "p")}
+		fields := strings.Split(strings.TrimPrefix(m, "p"), ".")
+		if f.FreePlacement() != (len(fields) == 2) || len(fields) > 2 {
+			return nil, errors.Errorf("invalid game record, bad move %q at move %d", m, i+1)
+		}
+		col, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, errors.Errorf("invalid game record, bad column %q at move %d", m, i+1)
+		}
+		move.Column = col - 1
+		if len(fields) == 2 {
+			row, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, errors.Errorf("invalid game record, bad row %q at move %d", m, i+1)
+			}
+			move.Row = row - 1
+		}
+		if _, _, err := f.Play(move); err != nil {
+			return nil, errors.Wrapf(err, "invalid game record, move %d", i+1)
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestNotationRoundTrip checks random games of every variant load back the same.
+func TestNotationRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, opts := range [][]Option{
+		nil,
+		{WithVariant("popout")},
+		{WithVariant("free")},
+		{WithVariant("cylinder")},
+		{WithBlockers(Cell{Row: 3, Col: 3}, Cell{Row: 0, Col: 0})},
+		{WithRanking()},
+	} {
+		for n := 0; n < 50; n++ {
+			f, err := NewConnectFour(4+rnd.Intn(8), 4+rnd.Intn(4), 2+rnd.Intn(2), 3+rnd.Intn(2), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			randomGame(t, f, rnd, rnd.Intn(f.Columns*f.Rows), nil)
+			g, err := ParseNotation(f.Notation())
+			if err != nil {
+				t.Fatalf("Unexpected error loading %s: %s", f.Notation(), err)
+			}
+			if g.Notation() != f.Notation() || g.Hash() != f.Hash() || g.GridState != f.GridState {
+				t.Fatalf("Unexpected game loaded.\nExpected:\t%s\nGot:\t\t%s", f.Notation(), g.Notation())
+			}
+		}
+	}
+}
+
+// TestParseNotationErrors checks the malformed game records are rejected.
+func TestParseNotationErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		record string
+		expect string
+	}{
+		{name: "empty", record: "", expect: "missing ':'"},
+		{name: "no moves", record: "7x6w4p2", expect: "missing ':'"},
+		{name: "missing player count", record: "7x6w4:44", expect: `header "7x6w4"`},
+		{name: "header garbage", record: "7x6w4p2x:44", expect: `header "7x6w4p2x"`},
+		{name: "header padding", record: "7x6w4p02:44", expect: `header "7x6w4p02"`},
+		{name: "header letters", record: "axbw4p2:44", expect: `header "axbw4p2"`},
+		{name: "invalid grid", record: "0x6w4p2:", expect: "invalid game record: invalid grid size: 0/6"},
+		{name: "variant", record: "7x6w4p2/chess:44", expect: "unknown variant 'chess'"},
+		{name: "blocker without row", record: "7x6w4p2#4:44", expect: `bad blocker "4"`},
+		{name: "blocker garbage", record: "7x6w4p2#4.6x:44", expect: `bad blocker "4.6x"`},
+		{name: "blocker out of the grid", record: "7x6w4p2#8.6:44", expect: "invalid game record: blocker 7/5 outside of the grid"},
+		{name: "teams mode", record: "7x6w4p4@both=1+3,2+4:", expect: `bad teams "both=1+3,2+4"`},
+		{name: "teams players", record: "7x6w4p4@mixed=1+5,2+4:", expect: `invalid team "1+5"`},
+		{name: "column too large", record: "7x6w4p2:48", expect: "move 2: 7 is an invalid move"},
+		{name: "column zero", record: "7x6w4p2:40", expect: "move 2: -1 is an invalid move"},
+		{name: "column not a number", record: "7x6w4p2:4x", expect: `bad column "x" at move 2`},
+		{name: "full column", record: "4x4w3p2:1111123", expect: "move 5: 0 is an invalid move"},
+		{name: "wide column too large", record: "10x6w4p2:1,10,11", expect: "move 3: 10 is an invalid move"},
+		{name: "wide empty column", record: "10x6w4p2:1,,2", expect: `bad column "" at move 2`},
+		{name: "pop in classic", record: "7x6w4p2:4p4", expect: "move 2: pop not allowed"},
+		{name: "pop of an empty column", record: "7x6w4p2/popout:4p3", expect: "move 2: 2 is an invalid pop"},
+		{name: "pop of an opponent", record: "7x6w4p2/popout:4p4", expect: "move 2: 3 is an invalid pop"},
+		{name: "pop without column", record: "7x6w4p2/popout:4p", expect: `bad column "p" at move 2`},
+		{name: "row in classic", record: "7x6w4p2:4.2", expect: `bad move "." at move 2`},
+		{name: "free without row", record: "3x3w3p2/free:2.2,1", expect: `bad move "1" at move 2`},
+		{name: "free row not a number", record: "3x3w3p2/free:2.2,1.x", expect: `bad row "1.x" at move 2`},
+		{name: "free column not a number", record: "3x3w3p2/free:2.2,.1", expect: `bad column ".1" at move 2`},
+		{name: "free extra field", record: "3x3w3p2/free:2.2,1.1.1", expect: `bad move "1.1.1" at move 2`},
+		{name: "free comma", record: "3x3w3p2/free:2,2", expect: `bad move "2" at move 1`},
+		{name: "free occupied", record: "3x3w3p2/free:2.2,2.2", expect: "move 2: 1/1 is an invalid move"},
+		{name: "free out of the grid", record: "3x3w3p2/free:2.2,4.1", expect: "move 2: 3/0 is an invalid move"},
+		{name: "free trailing comma", record: "3x3w3p2/free:2.2,", expect: `bad move "" at move 2`},
+		{name: "trailing garbage", record: "7x6w4p2:44 x", expect: `bad column " " at move 3`},
+		{name: "trailing moves", record: "7x6w4p2:12121212", expect: "game finished before move 8"},
+	} {
+		f, err := ParseNotation(tc.record)
+		if err == nil || !strings.Contains(err.Error(), tc.expect) {
+			t.Fatalf("[%s] Unexpected error loading %q.\nExpected:\t%s\nGot:\t\t%v", tc.name, tc.record, tc.expect, err)
+		}
+		if f != nil {
+			t.Fatalf("[%s] Unexpected game loaded from %q", tc.name, tc.record)
+		}
+	}
+}
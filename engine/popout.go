@@ -0,0 +1,154 @@
+package engine
+
+import "github.com/pkg/errors"
+
+// ErrNoPopOut is returned when popping a piece in a variant without pops.
+var ErrNoPopOut = errors.New("pop not allowed in this variant")
+
+// PopOut is the variant where a player may, instead of dropping a piece, remove one
+// of their own pieces from the bottom row. The pieces above it fall down by one row,
+// up to the first blocker.
+//
+// When a pop aligns pieces for several players, the popping player, or their team, wins.
+// A position occurring for the third time, with the same player to move, is a draw.
+// A full grid is only a draw when the player to move cannot pop.
+type PopOut struct {
+	Classic
+}
+
+// Name returns the name of the variant.
+func (PopOut) Name() string {
+	return "popout"
+}
+
+// LegalMoves returns the drops followed by the pops.
+func (r PopOut) LegalMoves(f *Four) []Move {
+	moves := r.Classic.LegalMoves(f)
+	for col, s := range f.Content[f.Rows-1] {
+		if s == f.CurPlayer {
+			moves = append(moves, Move{Player: f.CurPlayer, Column: col, Pop: true})
+		}
+	}
+	return moves
+}
+
+// Validate checks drops as Classic does and that pops remove one of the player's pieces.
+func (r PopOut) Validate(f *Four, m Move) error {
+	if !m.Pop {
+		return r.Classic.Validate(f, m)
+	}
+	if m.Column < 0 || m.Column >= f.Columns || f.Content[f.Rows-1][m.Column] != m.Player {
+		return errors.Wrapf(ErrInvalidMove, "%d is an invalid pop for player %d (%s)", m.Column, m.Player, m.Player)
+	}
+	return nil
+}
+
+// Apply drops the piece or pops the bottom one and shifts the column down.
+func (r PopOut) Apply(f *Four, m Move) Move {
+	if !m.Pop {
+		return r.Classic.Apply(f, m)
+	}
+	top := popTop(f, m.Column)
+	for x := f.Rows - 1; x > top; x-- {
+		f.Set(x, m.Column, f.Content[x-1][m.Column])
+	}
+	f.Set(top, m.Column, Empty)
+	m.Row = f.Rows - 1
+	return m
+}
+
+// Revert takes back the drop or puts the popped piece back.
+func (r PopOut) Revert(f *Four, m Move) {
+	if !m.Pop {
+		r.Classic.Revert(f, m)
+		return
+	}
+	for x := popTop(f, m.Column); x < f.Rows-1; x++ {
+		f.Set(x, m.Column, f.Content[x+1][m.Column])
+	}
+	f.Set(f.Rows-1, m.Column, m.Player)
+}
+
+// Terminal checks the lines created by the move, then for a full grid or a threefold repetition.
+func (r PopOut) Terminal(f *Four, m Move) (State, []Line) {
+	var (
+		ret   State
+		lines []Line
+	)
+	if m.Pop {
+		ret, lines = popLines(f, m.Column, m.Player)
+	} else if lines = f.LinesFrom(m.Row, m.Column); len(lines) != 0 {
+		ret = m.Player
+	}
+	if ret != Empty {
+		return ret, lines
+	}
+	if f.Full() && !canPop(f, f.CurPlayer) || f.repetitions() >= 3 {
+		return Stale, nil
+	}
+	return Empty, nil
+}
+
+// popLines returns the winner and winning lines after the given player popped in col.
+// All the pieces of the column moved, so every line going through it is checked.
+func popLines(f *Four, col int, player State) (State, []Line) {
+	winner := State(Empty)
+	var lines []Line
+	for x := 0; x < f.Rows; x++ {
+		s := f.Content[x][col]
+		if s == Empty {
+			continue
+		}
+		// Teammates share their lines, credit them to the popping player or the winner so far.
+		if f.SameTeam(s, player) {
+			s = player
+		} else if winner != Empty && f.SameTeam(s, winner) {
+			s = winner
+		}
+		for _, l := range f.LinesFrom(x, col) {
+			// The popping player takes precedence over the others.
+			if winner == Empty || (s == player && winner != player) {
+				winner, lines = s, nil
+			}
+			if s == winner && !hasLine(lines, l) {
+				lines = append(lines, l)
+			}
+		}
+	}
+	return winner, lines
+}
+
+// popTop returns the highest row moved by a pop in col, the pieces resting
+// on a blocker stay in place.
+func popTop(f *Four, col int) int {
+	x := f.Rows - 1
+	for x > 0 && f.Content[x-1][col] != Blocker {
+		x--
+	}
+	return x
+}
+
+// canPop returns true if the given player has a piece in the bottom row.
+func canPop(f *Four, player State) bool {
+	for _, s := range f.Content[f.Rows-1] {
+		if s == player {
+			return true
+		}
+	}
+	return false
+}
+
+// repetitions returns how many times the current position, including the player to move,
+// occurred during the game.
+func (f *Four) repetitions() int {
+	h, n := f.Hash(), 0
+	if h == f.zobrist.base^f.zobrist.turn[0] {
+		n++
+	}
+	for _, m := range f.History {
+		if m.hash == h {
+			n++
+		}
+	}
+	return n
+}
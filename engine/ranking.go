@@ -0,0 +1,84 @@
+package engine
+
+import "github.com/pkg/errors"
+
+// WithRanking enables the ranking mode: a player aligning nWin pieces is
+// ranked and leaves the rotation, their pieces staying on the grid as
+// obstacles. The game goes on until a single player is left, who is ranked
+// last, or until nobody can move, the players left sharing the last places.
+// The game is then won by the first ranked player, see Placements.
+func WithRanking() Option {
+	return func(f *Four) error {
+		f.Ranking = true
+		return nil
+	}
+}
+
+// checkRanking rejects the configurations the ranking mode does not support.
+func (f *Four) checkRanking() error {
+	if f.Ranking && len(f.Teams) != 0 {
+		return errors.New("ranking mode not available with teams")
+	}
+	return nil
+}
+
+// Rank returns the 1 indexed placement of the given player, 0 while unranked.
+func (f *Four) Rank(p State) int {
+	for i, elem := range f.Placements {
+		if elem == p {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// rank updates the placements with the state returned by the rules for the last move
+// and returns the resulting state of the game.
+func (f *Four) rank(ret State) State {
+	switch {
+	case ret == Stale:
+	case ret == Empty, f.Rank(ret) != 0:
+		// Lines of ranked players are already accounted for.
+		return Empty
+	default:
+		f.Placements = append(f.Placements, ret)
+		var left []State
+		for _, p := range f.AvailablePlayers {
+			if f.Rank(p) == 0 {
+				left = append(left, p)
+			}
+		}
+		if len(left) <= 1 {
+			f.Placements = append(f.Placements, left...)
+			return f.Placements[0]
+		}
+		// A pop may rank the player about to move.
+		if f.CurPlayer == ret {
+			f.advance()
+			f.History[len(f.History)-1].hash = f.Hash()
+		}
+		// Positions left dead by the ranking are caught by the rules on the next move.
+		if len(f.Rules.LegalMoves(f)) != 0 {
+			return Empty
+		}
+	}
+	// Nobody can move, the first ranked player, if any, wins.
+	if len(f.Placements) != 0 {
+		return f.Placements[0]
+	}
+	return Stale
+}
+
+// rankScore returns the score of the placement of the given player, from 1
+// for the first to 0 for the last. The players left unranked share the
+// remaining places.
+func (f *Four) rankScore(player State) float64 {
+	if f.NPlayers == 1 {
+		return 1
+	}
+	place := float64(f.Rank(player))
+	if place == 0 {
+		place = float64(len(f.Placements)+1+f.NPlayers) / 2
+	}
+	return (float64(f.NPlayers) - place) / float64(f.NPlayers-1)
+}
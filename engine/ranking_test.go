@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestRanking plays ranked games of 3 and 4 players to the end: the ranked
+// players leave the rotation, the game goes on until a single player is left.
+func TestRanking(t *testing.T) {
+	type step struct {
+		col        int
+		placements []State // After the move.
+		next       State   // Player to move after the move, Empty once over.
+	}
+	for _, tc := range []struct {
+		name     string
+		nPlayers int
+		opening  []int // Moves before the steps, nobody ranked.
+		steps    []step
+		scores   []float64 // Final scores, by player.
+	}{
+		{
+			name:     "3 players",
+			nPlayers: 3,
+			opening:  []int{0, 1, 2, 0, 1, 2, 0, 1, 2},
+			steps: []step{
+				{col: 0, placements: []State{Red}, next: Yellow},
+				{col: 4, placements: []State{Red}, next: Green},
+				{col: 4, placements: []State{Red}, next: Yellow}, // Red is skipped.
+				{col: 1, placements: []State{Red, Yellow, Green}},
+			},
+			scores: []float64{1, 0.5, 0},
+		},
+		{
+			name:     "4 players",
+			nPlayers: 4,
+			opening:  []int{0, 1, 2, 3, 0, 1, 2, 3, 0, 1, 2, 3},
+			steps: []step{
+				{col: 0, placements: []State{Red}, next: Yellow},
+				{col: 5, placements: []State{Red}, next: Green},
+				{col: 2, placements: []State{Red, Green}, next: Magenta},
+				{col: 5, placements: []State{Red, Green}, next: Yellow}, // Red and Green are skipped.
+				{col: 6, placements: []State{Red, Green}, next: Magenta},
+				{col: 3, placements: []State{Red, Green, Magenta, Yellow}},
+			},
+			scores: []float64{1, 0, 2. / 3, 1. / 3},
+		},
+	} {
+		f, err := NewConnectFour(DefaultCols, DefaultRows, tc.nPlayers, DefaultNWin, WithRanking())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, col := range tc.opening {
+			if _, _, err := f.PlayerMove(f.CurPlayer, col); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i, s := range tc.steps {
+			ret, _, err := f.PlayerMove(f.CurPlayer, s.col)
+			if err != nil {
+				t.Fatalf("[%s] Unexpected error at step %d: %s", tc.name, i+1, err)
+			}
+			if !reflect.DeepEqual(f.Placements, s.placements) {
+				t.Fatalf("[%s] Unexpected placements at step %d.\nExpected:\t%v\nGot:\t\t%v", tc.name, i+1, s.placements, f.Placements)
+			}
+			if s.next == Empty {
+				break
+			}
+			if ret != Empty || f.GridState != Empty || f.CurPlayer != s.next {
+				t.Fatalf("[%s] Unexpected state at step %d.\nExpected:\t%d, player %d to move\nGot:\t\t%d, player %d to move", tc.name, i+1, Empty, s.next, f.GridState, f.CurPlayer)
+			}
+			for j, p := range s.placements {
+				if rank := f.Rank(p); rank != j+1 {
+					t.Fatalf("[%s] Unexpected rank of player %d at step %d.\nExpected:\t%d\nGot:\t\t%d", tc.name, p, i+1, j+1, rank)
+				}
+			}
+		}
+
+		// The first ranked player wins.
+		if f.GridState != Red || len(f.WinningLines) == 0 || f.State(f.WinningLines[0][0].Row, f.WinningLines[0][0].Col) != Red {
+			t.Fatalf("[%s] Unexpected end of the game.\nExpected:\t%d\nGot:\t\t%d, lines %v", tc.name, Red, f.GridState, f.WinningLines)
+		}
+		if _, _, err := f.PlayerMove(f.CurPlayer, 6); err != ErrGameOver {
+			t.Fatalf("[%s] Unexpected error playing after the end.\nExpected:\t%v\nGot:\t\t%v", tc.name, ErrGameOver, err)
+		}
+		for i, p := range f.AvailablePlayers {
+			if got := f.Rules.Score(f, p); got != tc.scores[i] {
+				t.Fatalf("[%s] Unexpected final score of player %d.\nExpected:\t%v\nGot:\t\t%v", tc.name, p, tc.scores[i], got)
+			}
+		}
+
+		// The standings survive the notation.
+		g, err := ParseNotation(f.Notation())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(g.Placements, f.Placements) || g.GridState != f.GridState {
+			t.Fatalf("[%s] Unexpected standings after loading %s.\nExpected:\t%v\nGot:\t\t%v", tc.name, f.Notation(), f.Placements, g.Placements)
+		}
+
+		// Taking back the last move reopens the game for its player.
+		last := f.History[len(f.History)-1]
+		if _, err := f.Undo(); err != nil {
+			t.Fatal(err)
+		}
+		if expect := tc.steps[len(tc.steps)-2].placements; !reflect.DeepEqual(f.Placements, expect) || f.GridState != Empty || f.CurPlayer != last.Player {
+			t.Fatalf("[%s] Unexpected state after undo.\nExpected:\t%v, player %d to move\nGot:\t\t%v, player %d to move", tc.name, expect, last.Player, f.Placements, f.CurPlayer)
+		}
+	}
+}
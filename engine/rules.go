@@ -0,0 +1,136 @@
+package engine
+
+import "github.com/pkg/errors"
+
+// Rules defines a game variant. The engine handles the turns, history,
+// hashing and notifications, the rules handle the grid.
+//
+// Rules are shared between games and must be stateless.
+type Rules interface {
+	// Name returns the name of the variant, as registered in Variants.
+	Name() string
+	// Init validates the configuration of a new game and prepares its grid.
+	Init(f *Four) error
+	// LegalMoves returns the moves the current player can play.
+	LegalMoves(f *Four) []Move
+	// Validate checks if the given move is legal. The player is known to be the current one.
+	Validate(f *Four, m Move) error
+	// Apply updates the grid with the validated move and returns it completed,
+	// e.g. with the row where the piece landed.
+	Apply(f *Four, m Move) Move
+	// Revert updates the grid to take back the given move, the last one played.
+	Revert(f *Four, m Move)
+	// Terminal returns the state of the game after the given move, the last one played,
+	// and upon victory, the winning lines. The engine credits the lines mixing
+	// the colors of a team to its first player.
+	Terminal(f *Four, m Move) (State, []Line)
+	// Score returns the result of the game for the given player,
+	// from 0 for a loss to 1 for a win. Unfinished games score as stale.
+	Score(f *Four, player State) float64
+}
+
+// Variants holds the available rules by name.
+var Variants = map[string]Rules{
+	"classic":  Classic{},
+	"popout":   PopOut{},
+	"free":     Free{},
+	"cylinder": Cylinder{},
+}
+
+// Option configures a new game.
+type Option func(*Four) error
+
+// WithRules sets the rules of the game.
+func WithRules(r Rules) Option {
+	return func(f *Four) error {
+		f.Rules = r
+		return nil
+	}
+}
+
+// WithVariant sets the rules of the game by name. See Variants.
+func WithVariant(name string) Option {
+	return func(f *Four) error {
+		r, ok := Variants[name]
+		if !ok {
+			return errors.Errorf("unknown variant '%s'", name)
+		}
+		f.Rules = r
+		return nil
+	}
+}
+
+// Classic is the default connect four: pieces are dropped in a column
+// and the first player to align nWin pieces wins.
+type Classic struct{}
+
+// Name returns the name of the variant.
+func (Classic) Name() string {
+	return DefaultVariant
+}
+
+// Init is a no op, any configuration is valid.
+func (Classic) Init(f *Four) error {
+	return nil
+}
+
+// LegalMoves returns a drop for each column not full.
+func (Classic) LegalMoves(f *Four) []Move {
+	var moves []Move
+	for col := 0; col < f.Columns; col++ {
+		if f.Content[0][col] == Empty {
+			moves = append(moves, Move{Player: f.CurPlayer, Column: col})
+		}
+	}
+	return moves
+}
+
+// Validate checks if the column exists and is not full.
+func (Classic) Validate(f *Four, m Move) error {
+	if m.Pop {
+		return ErrNoPopOut
+	}
+	// Les than 0, too big or column already full.
+	if m.Column < 0 || m.Column >= f.Columns || f.Content[0][m.Column] != Empty {
+		return errors.Wrapf(ErrInvalidMove, "%d is an invalid move for player %d (%s)", m.Column, m.Player, m.Player)
+	}
+	return nil
+}
+
+// Apply drops the piece in the column.
+func (Classic) Apply(f *Four, m Move) Move {
+	m.Row = f.Landing(m.Column)
+	f.Set(m.Row, m.Column, m.Player)
+	return m
+}
+
+// Revert removes the dropped piece.
+func (Classic) Revert(f *Four, m Move) {
+	f.Set(m.Row, m.Column, Empty)
+}
+
+// Terminal checks the lines going through the new piece, then if the grid is full
+// or if no line can be completed anymore.
+func (Classic) Terminal(f *Four, m Move) (State, []Line) {
+	if lines := f.LinesFrom(m.Row, m.Column); len(lines) != 0 {
+		return m.Player, lines
+	}
+	if f.Full() || !f.Live() {
+		return Stale, nil
+	}
+	return Empty, nil
+}
+
+// Score returns 1 to the winner and their team, 0 to the others and splits stale games evenly.
+// In ranking mode, the score follows the placement of the player.
+func (Classic) Score(f *Four, player State) float64 {
+	switch {
+	case f.Ranking:
+		return f.rankScore(player)
+	case f.GridState == Empty, f.GridState == Stale:
+		return 1 / float64(f.sides())
+	case f.SameTeam(f.GridState, player):
+		return 1
+	}
+	return 0
+}
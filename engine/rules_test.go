@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// sameLines returns true if both lists hold the same lines, in any order.
+func sameLines(a, b []Line) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, l := range a {
+		if !hasLine(b, l) {
+			return false
+		}
+	}
+	return true
+}
+
+// holdsLine returns true if the side of the given player has a line on the grid.
+func holdsLine(f *Four, p State) bool {
+	for x := range f.Content {
+		for y, s := range f.Content[x] {
+			if s != Empty && s != Blocker && f.side(s) == f.side(p) && len(f.LinesFrom(x, y)) != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TestTerminalCompute plays random games on every variant and checks that the
+// state and lines found by the rules from the last move agree with a full rescan,
+// which leaves the game untouched.
+func TestTerminalCompute(t *testing.T) {
+	for _, elem := range []struct {
+		name                          string
+		columns, rows, nPlayers, nWin int
+		opts                          []Option
+	}{
+		{name: "classic", columns: 7, rows: 6, nPlayers: 2, nWin: 4},
+		{name: "classic small", columns: 4, rows: 4, nPlayers: 2, nWin: 3},
+		{name: "classic 3 players", columns: 8, rows: 7, nPlayers: 3, nWin: 4},
+		{name: "classic large", columns: 12, rows: 10, nPlayers: 2, nWin: 5},
+		{name: "popout", columns: 7, rows: 6, nPlayers: 2, nWin: 4, opts: []Option{WithVariant("popout")}},
+		{name: "popout 3 players", columns: 5, rows: 4, nPlayers: 3, nWin: 3, opts: []Option{WithVariant("popout")}},
+		{name: "free", columns: 6, rows: 5, nPlayers: 2, nWin: 4, opts: []Option{WithVariant("free")}},
+		{name: "cylinder", columns: 7, rows: 6, nPlayers: 2, nWin: 4, opts: []Option{WithVariant("cylinder")}},
+		{name: "cylinder narrow", columns: 4, rows: 6, nPlayers: 2, nWin: 4, opts: []Option{WithVariant("cylinder")}},
+		{name: "blockers", columns: 7, rows: 6, nPlayers: 2, nWin: 4, opts: []Option{WithBlockers(Cell{Row: 5, Col: 3}, Cell{Row: 2, Col: 1})}},
+		{name: "teams", columns: 8, rows: 7, nPlayers: 4, nWin: 4, opts: []Option{WithTeams(false, []State{Red, Green}, []State{Yellow, Magenta})}},
+		{name: "mixed teams", columns: 8, rows: 7, nPlayers: 4, nWin: 4, opts: []Option{WithTeams(true, []State{Red, Green}, []State{Yellow, Magenta})}},
+		{name: "mixed teams popout", columns: 7, rows: 6, nPlayers: 4, nWin: 4, opts: []Option{WithVariant("popout"), WithTeams(true, []State{Red, Green}, []State{Yellow, Magenta})}},
+		{name: "mixed teams free", columns: 6, rows: 5, nPlayers: 4, nWin: 4, opts: []Option{WithVariant("free"), WithTeams(true, []State{Red, Green}, []State{Yellow, Magenta})}},
+	} {
+		rnd := rand.New(rand.NewSource(1))
+		for n := 0; n < 300; n++ {
+			f, err := NewConnectFour(elem.columns, elem.rows, elem.nPlayers, elem.nWin, elem.opts...)
+			if err != nil {
+				t.Fatalf("[%s] Unexpected error creating the game: %s", elem.name, err)
+			}
+			randomGame(t, f, rnd, 200, func() {
+				m := f.History[len(f.History)-1]
+				before := takeSnapshot(f)
+				got, lines := f.Scan()
+				if after := takeSnapshot(f); !reflect.DeepEqual(after, before) {
+					t.Fatalf("[%s] Unexpected change of the game by the rescan on move %d", elem.name, len(f.History))
+				}
+				switch {
+				case m.GridState == Stale:
+					// Early draws and repetitions are left to the rules, the rescan only knows full grids.
+					if got != Stale && got != Empty {
+						t.Fatalf("[%s] Unexpected rescan after a draw on move %d.\nExpected:\t%d or %d\nGot:\t\t%d", elem.name, len(f.History), Stale, Empty, got)
+					}
+				case m.Pop && got != Empty && holdsLine(f, m.GridState):
+					// A pop may complete lines for several sides, the rules credit the popping one.
+				case got != m.GridState:
+					t.Fatalf("[%s] Unexpected rescan on move %d.\nExpected:\t%d\nGot:\t\t%d", elem.name, len(f.History), m.GridState, got)
+				case !sameLines(lines, f.WinningLines):
+					// Before the last move, nobody had a line: all the lines of the winner go through it.
+					t.Fatalf("[%s] Unexpected lines of the rescan on move %d.\nExpected:\t%v\nGot:\t\t%v", elem.name, len(f.History), f.WinningLines, lines)
+				}
+				// The state decided by the rules is kept.
+				if got := f.Compute(); got != m.GridState || f.GridState != m.GridState {
+					t.Fatalf("[%s] Unexpected state after compute on move %d.\nExpected:\t%d\nGot:\t\t%d, %d", elem.name, len(f.History), m.GridState, got, f.GridState)
+				}
+			})
+		}
+	}
+}
+
+func TestPlayAfterEnd(t *testing.T) {
+	f, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []int{0, 1, 0, 1, 0, 1, 0} {
+		if _, _, err := f.PlayerMove(f.CurPlayer, col); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f.GridState != Red {
+		t.Fatalf("Unexpected grid state.\nExpected:\t%d\nGot:\t\t%d", Red, f.GridState)
+	}
+	ret, _, err := f.PlayerMove(f.CurPlayer, 2)
+	if err != ErrGameOver {
+		t.Fatalf("Unexpected error playing after the end.\nExpected:\t%v\nGot:\t\t%v", ErrGameOver, err)
+	}
+	if ret != Empty || f.GridState != Red || len(f.History) != 7 {
+		t.Fatalf("Unexpected game after a rejected move: state %d, grid state %d, %d moves", ret, f.GridState, len(f.History))
+	}
+
+	// Taking back the winning move resumes the game.
+	if _, err := f.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.PlayerMove(f.CurPlayer, 2); err != nil {
+		t.Fatalf("Unexpected error playing after undo: %s", err)
+	}
+}
+
+// TestComputeAfterEnd checks the games ended by the rules, without a line
+// nor a full grid, stay over after a rescan.
+func TestComputeAfterEnd(t *testing.T) {
+	// A 3 player game dead long before the grid is full.
+	rnd := rand.New(rand.NewSource(1))
+	var early *Four
+	for early == nil {
+		f, err := NewConnectFour(DefaultCols, DefaultRows, 3, DefaultNWin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		randomGame(t, f, rnd, f.Columns*f.Rows, nil)
+		if f.GridState == Stale && !f.Full() {
+			early = f
+		}
+	}
+
+	// Drops and pops bringing the position back, until its third occurrence.
+	repeated, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin, WithVariant("popout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		for _, m := range []Move{{Player: Red, Column: 0}, {Player: Yellow, Column: 1}, {Player: Red, Column: 0, Pop: true}, {Player: Yellow, Column: 1, Pop: true}} {
+			if _, _, err := repeated.Play(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for name, f := range map[string]*Four{"early draw": early, "repetition": repeated} {
+		if f.GridState != Stale {
+			t.Fatalf("[%s] Unexpected grid state of %s.\nExpected:\t%d\nGot:\t\t%d", name, f.Notation(), Stale, f.GridState)
+		}
+		if got := f.Compute(); got != Stale || f.GridState != Stale {
+			t.Fatalf("[%s] Unexpected state after compute of %s.\nExpected:\t%d\nGot:\t\t%d, %d", name, f.Notation(), Stale, got, f.GridState)
+		}
+		if _, _, err := f.Play(f.Rules.LegalMoves(f)[0]); err != ErrGameOver {
+			t.Fatalf("[%s] Unexpected error playing after compute.\nExpected:\t%v\nGot:\t\t%v", name, ErrGameOver, err)
+		}
+	}
+}
+
+// TestMixedTeamWinner checks a line mixing the colors of a team is credited
+// to the first player of the team, whoever completed it.
+func TestMixedTeamWinner(t *testing.T) {
+	f, err := NewConnectFour(DefaultCols, DefaultRows, 4, DefaultNWin, WithTeams(true, []State{Red, Green}, []State{Yellow, Magenta}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Red, Yellow, Green, Magenta: Red and Green stack in the first column.
+	for _, col := range []int{0, 1, 0, 1, 0, 2, 0} {
+		if _, _, err := f.PlayerMove(f.CurPlayer, col); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m := f.History[len(f.History)-1]; m.Player != Green || m.GridState != Red || f.GridState != Red {
+		t.Fatalf("Unexpected winner of the move of %d.\nExpected:\t%d\nGot:\t\t%d, %d", m.Player, Red, m.GridState, f.GridState)
+	}
+	if len(f.WinningLines) != 1 || len(f.WinningLines[0]) != DefaultNWin {
+		t.Fatalf("Unexpected winning lines: %v", f.WinningLines)
+	}
+	if got, _ := f.Scan(); got != Red {
+		t.Fatalf("Unexpected rescan.\nExpected:\t%d\nGot:\t\t%d", Red, got)
+	}
+
+	// Loading the game finds the same winner.
+	g, err := ParseNotation(f.Notation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.GridState != Red {
+		t.Fatalf("Unexpected winner after loading %s.\nExpected:\t%d\nGot:\t\t%d", f.Notation(), Red, g.GridState)
+	}
+}
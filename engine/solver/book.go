@@ -0,0 +1,95 @@
+package solver
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LoadBook loads an opening book, adding to the positions already known.
+//
+// The book is a text file with one position per line: the sequence of
+// columns played, 1 indexed, followed by the score of the position.
+// Extra fields are ignored, so the output of the analyze runtime is a valid
+// book. Empty lines and lines starting with '#' are skipped.
+//
+//	4453 -2
+//	444444 1
+func (s *Solver) LoadBook(r io.Reader) error {
+	s.Lock()
+	defer s.Unlock()
+
+	scanner := bufio.NewScanner(r)
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return errors.Errorf("invalid book entry line %d: %q", i, line)
+		}
+		p, err := parseMoves(fields[0])
+		if err != nil {
+			return errors.Wrapf(err, "invalid book entry line %d", i)
+		}
+		score, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return errors.Wrapf(err, "invalid book score line %d", i)
+		}
+		// The mirrored position has the same score.
+		s.book[p.key()] = score
+		mirrored := p.mirror()
+		s.book[mirrored.key()] = score
+	}
+	return errors.Wrap(scanner.Err(), "error reading book")
+}
+
+// LoadBookFile loads the opening book from the given file. See LoadBook.
+func (s *Solver) LoadBookFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "error opening book")
+	}
+	defer func() { _ = f.Close() }() // Best effort.
+	return s.LoadBook(f)
+}
+
+// parseMoves returns the position after the given 1 indexed columns.
+func parseMoves(moves string) (position, error) {
+	var p position
+	for i, c := range moves {
+		col := int(c - '1')
+		if col < 0 || col >= width {
+			return p, errors.Errorf("invalid column %q at move %d", c, i+1)
+		}
+		if !p.canPlay(col) {
+			return p, errors.Errorf("column %d full at move %d", col+1, i+1)
+		}
+		if p.isWinningMove(col) {
+			return p, errors.Errorf("game already won at move %d", i+1)
+		}
+		p.play(col)
+	}
+	return p, nil
+}
+
+// mirror returns the position flipped horizontally.
+func (p *position) mirror() position {
+	m := position{moves: p.moves}
+	for col := 0; col < width; col++ {
+		current, mask := p.current&columnMask(col), p.mask&columnMask(col)
+		if d := (width - 1 - 2*col) * (height + 1); d >= 0 {
+			m.current |= current << uint(d)
+			m.mask |= mask << uint(d)
+		} else {
+			m.current |= current >> uint(-d)
+			m.mask |= mask >> uint(-d)
+		}
+	}
+	return m
+}
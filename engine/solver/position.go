@@ -0,0 +1,135 @@
+package solver
+
+import "github.com/creack/gofour/engine"
+
+// Dimensions of the solved configuration.
+const (
+	width  = engine.DefaultCols
+	height = engine.DefaultRows
+	cells  = width * height
+
+	minScore = -(cells)/2 + 3
+	maxScore = (cells+1)/2 - 3
+)
+
+// Masks of the grid, using the same layout as engine.Bitboard.
+var (
+	bottomMask = func() uint64 {
+		var m uint64
+		for i := uint(0); i < width; i++ {
+			m |= 1 << (i * (height + 1))
+		}
+		return m
+	}()
+	boardMask = bottomMask * (1<<height - 1)
+)
+
+// position is a compact 7x6 position, seen from the player to move.
+type position struct {
+	current uint64 // Pieces of the player to move.
+	mask    uint64 // All the pieces.
+	moves   int    // Number of pieces played.
+}
+
+func topMask(col int) uint64 {
+	return 1 << uint(height-1+col*(height+1))
+}
+
+func bottomMaskCol(col int) uint64 {
+	return 1 << uint(col*(height+1))
+}
+
+func columnMask(col int) uint64 {
+	return (1<<height - 1) << uint(col*(height+1))
+}
+
+// key uniquely identifies the position.
+func (p *position) key() uint64 {
+	return p.current + p.mask
+}
+
+// canPlay returns true if the column is not full.
+func (p *position) canPlay(col int) bool {
+	return p.mask&topMask(col) == 0
+}
+
+// play drops a piece in the given column.
+func (p *position) play(col int) {
+	p.playMove((p.mask + bottomMaskCol(col)) & columnMask(col))
+}
+
+// playMove plays the given move mask.
+func (p *position) playMove(move uint64) {
+	p.current ^= p.mask
+	p.mask |= move
+	p.moves++
+}
+
+// isWinningMove returns true if playing the column wins the game for the player to move.
+func (p *position) isWinningMove(col int) bool {
+	return p.winningPositions()&p.possible()&columnMask(col) != 0
+}
+
+// canWinNext returns true if the player to move can win with this move.
+func (p *position) canWinNext() bool {
+	return p.winningPositions()&p.possible() != 0
+}
+
+// possible returns the mask of the playable cells.
+func (p *position) possible() uint64 {
+	return (p.mask + bottomMask) & boardMask
+}
+
+// nonLosingMoves returns the mask of the moves that do not hand a direct win to the opponent.
+func (p *position) nonLosingMoves() uint64 {
+	possible := p.possible()
+	opponentWin := p.opponentWinningPositions()
+	if forced := possible & opponentWin; forced != 0 {
+		// More than one forced move, we lose.
+		if forced&(forced-1) != 0 {
+			return 0
+		}
+		possible = forced
+	}
+	// Do not play below an opponent's winning cell.
+	return possible &^ (opponentWin >> 1)
+}
+
+// moveScore scores a move by the number of winning cells it creates.
+func (p *position) moveScore(move uint64) int {
+	return popcount(winningPositions(p.current|move, p.mask))
+}
+
+func (p *position) winningPositions() uint64 {
+	return winningPositions(p.current, p.mask)
+}
+
+func (p *position) opponentWinningPositions() uint64 {
+	return winningPositions(p.current^p.mask, p.mask)
+}
+
+// winningPositions returns the empty cells completing a line for the given pieces.
+func winningPositions(pos, mask uint64) uint64 {
+	// Vertical.
+	r := (pos << 1) & (pos << 2) & (pos << 3)
+
+	// Horizontal and both diagonals.
+	for _, d := range [3]uint{height + 1, height, height + 2} {
+		p := (pos << d) & (pos << (2 * d))
+		r |= p & (pos << (3 * d))
+		r |= p & (pos >> d)
+		p = (pos >> d) & (pos >> (2 * d))
+		r |= p & (pos << d)
+		r |= p & (pos >> (3 * d))
+	}
+	return r & (boardMask ^ mask)
+}
+
+// popcount returns the number of bits set.
+func popcount(m uint64) int {
+	n := 0
+	for ; m != 0; n++ {
+		m &= m - 1
+	}
+	return n
+}
@@ -0,0 +1,265 @@
+// Package solver provides a perfect solver for the default connect four:
+// 7 columns, 6 rows, 4 to win and 2 players.
+//
+// Scores follow the usual convention: 0 is a draw, a positive score means the
+// player to move wins and a negative one that the opponent does. The sooner the
+// win, the higher the score: 1 when winning with one's last piece, 18 when
+// winning with the 4th piece. See Distance to convert a score in plies.
+package solver
+
+import (
+	"sync"
+
+	"github.com/creack/gofour/engine"
+	"github.com/pkg/errors"
+)
+
+// Common errors.
+var (
+	ErrUnsupported = errors.New("only the classic 7x6 grid, 4 to win with 2 players can be solved")
+	ErrGameOver    = errors.New("game is finished")
+)
+
+// tableSize is a prime larger than 2^17, so 32 bits of a 49 bits key are enough
+// to tell positions sharing a slot apart.
+const tableSize = 4194319
+
+// table is a transposition table specialized for the solver's bounds.
+type table struct {
+	keys   []uint32
+	values []int8
+}
+
+func (t *table) put(key uint64, v int8) {
+	i := key % tableSize
+	t.keys[i] = uint32(key)
+	t.values[i] = v
+}
+
+func (t *table) get(key uint64) int8 {
+	i := key % tableSize
+	if t.keys[i] != uint32(key) {
+		return 0
+	}
+	return t.values[i]
+}
+
+// Solver holds the transposition table and opening book.
+// It can be reused between positions and is safe for concurrent use.
+type Solver struct {
+	sync.Mutex
+
+	table table
+	book  map[uint64]int
+	order [width]int // Columns, center first.
+	nodes int
+}
+
+// New instantiates a solver.
+func New() *Solver {
+	s := &Solver{
+		table: table{
+			keys:   make([]uint32, tableSize),
+			values: make([]int8, tableSize),
+		},
+		book: map[uint64]int{},
+	}
+	for i := range s.order {
+		s.order[i] = width/2 + (1-2*(i%2))*(i+1)/2
+	}
+	return s
+}
+
+// Solve returns the score of the position for the player to move and the best column to play.
+// The position is solved once, or looked up in the book, then each column is
+// checked against the score with a null window search, center first.
+func (s *Solver) Solve(f *engine.Four) (score, col int, err error) {
+	if f.Columns != width || f.Rows != height || f.NWin != 4 || f.NPlayers != 2 || f.Variant != engine.DefaultVariant || len(f.Blockers) != 0 {
+		return 0, -1, ErrUnsupported
+	}
+	if f.GridState != engine.Empty {
+		return 0, -1, ErrGameOver
+	}
+	var p position
+	for _, m := range f.History {
+		p.play(m.Column)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for _, c := range s.order {
+		if p.canPlay(c) && p.isWinningMove(c) {
+			return (cells + 1 - p.moves) / 2, c, nil
+		}
+	}
+	score = s.solve(p)
+	for _, c := range s.order {
+		if !p.canPlay(c) {
+			continue
+		}
+		next := p
+		next.play(c)
+		// The best columns leave the opponent with the opposite score.
+		if s.atMost(next, -score) {
+			return score, c, nil
+		}
+	}
+	return 0, -1, errors.Errorf("no column reaching the score %d", score)
+}
+
+// Distance returns the number of plies until the end of the game with perfect play,
+// winning move included, given the number of pieces played and the score.
+// Returns 0 for a draw.
+func Distance(moves, score int) int {
+	if score == 0 {
+		return 0
+	}
+	// The winner plays when the piece count has their parity.
+	parity, s := moves%2, score
+	if score < 0 {
+		parity, s = (moves+1)%2, -score
+	}
+	n := cells + 1 - 2*s
+	if n%2 != parity {
+		n--
+	}
+	return n - moves + 1
+}
+
+// solve returns the exact score of the position, which must not be won in one move.
+// Narrows the score window with null window searches.
+func (s *Solver) solve(p position) int {
+	if v, ok := s.book[p.key()]; ok {
+		return v
+	}
+	if p.canWinNext() {
+		return (cells + 1 - p.moves) / 2
+	}
+	min, max := -(cells-p.moves)/2, (cells+1-p.moves)/2
+	for min < max {
+		med := min + (max-min)/2
+		if med <= 0 && min/2 < med {
+			med = min / 2
+		} else if med >= 0 && max/2 > med {
+			med = max / 2
+		}
+		// Is the score above med?
+		if r := s.negamax(p, med, med+1); r <= med {
+			max = r
+		} else {
+			min = r
+		}
+	}
+	return min
+}
+
+// atMost returns true if the score of the position is at most v.
+func (s *Solver) atMost(p position, v int) bool {
+	if score, ok := s.book[p.key()]; ok {
+		return score <= v
+	}
+	if p.canWinNext() {
+		return (cells+1-p.moves)/2 <= v
+	}
+	return s.negamax(p, v, v+1) <= v
+}
+
+// negamax returns the score of the position within the alpha/beta window.
+// The position must not be won in one move.
+func (s *Solver) negamax(p position, alpha, beta int) int {
+	s.nodes++
+
+	next := p.nonLosingMoves()
+	if next == 0 {
+		return -(cells - p.moves) / 2
+	}
+	if p.moves >= cells-2 {
+		return 0
+	}
+
+	// We cannot win right away nor lose next move.
+	if min := -(cells - 2 - p.moves) / 2; alpha < min {
+		alpha = min
+		if alpha >= beta {
+			return alpha
+		}
+	}
+	max := (cells - 1 - p.moves) / 2
+	if v := int(s.table.get(p.key())); v != 0 {
+		if v > maxScore-minScore+1 {
+			// Lower bound.
+			if min := v + 2*minScore - maxScore - 2; alpha < min {
+				alpha = min
+				if alpha >= beta {
+					return alpha
+				}
+			}
+		} else {
+			// Upper bound.
+			max = v + minScore - 1
+		}
+	}
+	if beta > max {
+		beta = max
+		if alpha >= beta {
+			return beta
+		}
+	}
+
+	// Sort the moves by the number of winning cells they create, center first on ties.
+	var (
+		moves  [width]uint64
+		scores [width]int
+		n      int
+	)
+	for i := width - 1; i >= 0; i-- {
+		move := next & columnMask(s.order[i])
+		if move == 0 {
+			continue
+		}
+		score := p.moveScore(move)
+		j := n
+		for ; j > 0 && scores[j-1] > score; j-- {
+			moves[j], scores[j] = moves[j-1], scores[j-1]
+		}
+		moves[j], scores[j] = move, score
+		n++
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		child := p
+		child.playMove(moves[i])
+		score := -s.negamax(child, -beta, -alpha)
+		if score >= beta {
+			s.table.put(p.key(), int8(score+maxScore-2*minScore+2))
+			return score
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+	s.table.put(p.key(), int8(alpha-minScore+1))
+	return alpha
+}
+
+// Default solver, instantiated on first use.
+var (
+	defaultMu     sync.Mutex
+	defaultSolver *Solver
+)
+
+// Default returns the shared solver.
+func Default() *Solver {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultSolver == nil {
+		defaultSolver = New()
+	}
+	return defaultSolver
+}
+
+// Solve solves the position using the shared solver.
+func Solve(f *engine.Four) (score, col int, err error) {
+	return Default().Solve(f)
+}
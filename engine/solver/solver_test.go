@@ -0,0 +1,141 @@
+package solver
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/creack/gofour/engine"
+)
+
+// newGame returns a default game after the given 1 indexed columns.
+func newGame(t *testing.T, moves string) *engine.Four {
+	f, err := engine.NewConnectFour(width, height, 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range moves {
+		if _, _, err := f.PlayerMove(f.CurPlayer, int(c-'1')); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+// brute returns the score of the game for the player to move with a plain negamax.
+func brute(f *engine.Four) int {
+	best := -cells
+	for _, m := range f.Rules.LegalMoves(f) {
+		ret, _, _ := f.Play(m)
+		v := 0
+		switch ret {
+		case engine.Empty:
+			v = -brute(f)
+		case engine.Stale:
+		default:
+			v = (cells + 2 - len(f.History)) / 2
+		}
+		_, _ = f.Undo()
+		if v > best {
+			best = v
+		}
+	}
+	return best
+}
+
+// TestSolveBrute checks the scores and columns of the solver against a plain negamax on late positions.
+func TestSolveBrute(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	s := New()
+	for n := 0; n < 30; {
+		f, err := engine.NewConnectFour(width, height, 2, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for len(f.History) < 30 && f.GridState == engine.Empty {
+			moves := f.Rules.LegalMoves(f)
+			_, _, _ = f.Play(moves[rnd.Intn(len(moves))])
+		}
+		if f.GridState != engine.Empty {
+			continue
+		}
+		n++
+		score, col, err := s.Solve(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := brute(f); score != expect {
+			t.Fatalf("Unexpected score for %s.\nExpected:\t%d\nGot:\t\t%d", f.Notation(), expect, score)
+		}
+		// The column reaches the score.
+		ret, _, err := f.PlayerMove(f.CurPlayer, col)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := 0
+		switch ret {
+		case engine.Empty:
+			got = -brute(f)
+		case engine.Stale:
+		default:
+			got = (cells + 2 - len(f.History)) / 2
+		}
+		if got != score {
+			t.Fatalf("Unexpected score of column %d for %s.\nExpected:\t%d\nGot:\t\t%d", col+1, f.Notation(), score, got)
+		}
+	}
+}
+
+// TestSolveBook checks the known positions are not searched.
+func TestSolveBook(t *testing.T) {
+	const moves = "4444335"
+	s := New()
+	score, col, err := s.Solve(newGame(t, moves))
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := fmt.Sprintf("%s %d\n", moves, score)
+	for c := 1; c <= width; c++ {
+		f := newGame(t, moves)
+		if _, _, err := f.PlayerMove(f.CurPlayer, c-1); err != nil {
+			continue
+		}
+		if f.GridState != engine.Empty {
+			continue
+		}
+		v, _, err := s.Solve(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		book += fmt.Sprintf("%s%d %d\n", moves, c, v)
+	}
+
+	s = New()
+	if err := s.LoadBook(strings.NewReader(book)); err != nil {
+		t.Fatal(err)
+	}
+	got, gotCol, err := s.Solve(newGame(t, moves))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != score || gotCol != col {
+		t.Fatalf("Unexpected result from the book.\nExpected:\t%d, column %d\nGot:\t\t%d, column %d", score, col+1, got, gotCol+1)
+	}
+	if s.nodes != 0 {
+		t.Fatalf("Unexpected search of %d nodes for a known position", s.nodes)
+	}
+}
+
+func TestSolveErrors(t *testing.T) {
+	f, err := engine.NewConnectFour(8, 7, 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := New().Solve(f); err != ErrUnsupported {
+		t.Fatalf("Unexpected error.\nExpected:\t%v\nGot:\t\t%v", ErrUnsupported, err)
+	}
+	if _, _, err := New().Solve(newGame(t, "1212121")); err != ErrGameOver {
+		t.Fatalf("Unexpected error.\nExpected:\t%v\nGot:\t\t%v", ErrGameOver, err)
+	}
+}
@@ -1,6 +1,9 @@
 package engine
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // State is the enum type for the grid state.
 type State int
@@ -16,8 +19,10 @@ const (
 	Cyan
 	Black
 	Stale
+	Blocker // Neutral cell, part of no line and owned by nobody.
 
-	PlayerUnicode = '●'
+	PlayerUnicode  = '●'
+	BlockerUnicode = '■'
 )
 
 // AvailablePlayers is the list of available player colors.
@@ -39,7 +44,15 @@ func (s State) String() string {
 		return fmt.Sprintf("\x1b[1;36m%c\x1b[0m", PlayerUnicode)
 	case Black:
 		return fmt.Sprintf("\x1b[1;30m%c\x1b[0m", PlayerUnicode)
+	case Blocker:
+		return fmt.Sprintf("\x1b[0;37m%c\x1b[0m", BlockerUnicode)
 	default:
 		return fmt.Sprintf("\x1b[1;37m%c\x1b[0m", PlayerUnicode)
 	}
 }
+
+// Highlight renders the state in reverse video, e.g. for the winning lines.
+// The rendering has the same length as String, so it can be aligned the same way.
+func (s State) Highlight() string {
+	return strings.Replace(s.String(), "\x1b[1;", "\x1b[7;", 1)
+}
@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Team line modes, see WithTeams.
+const (
+	TeamLinesMixed = "mixed" // Lines may mix the colors of a team.
+	TeamLinesColor = "color" // Lines must be of a single color.
+)
+
+// WithTeams groups the players in teams. The teams take turns: the first
+// player of each team, then the second ones, and so on.
+// When mixed is true, a line made of any colors of a team wins for it,
+// otherwise the line must be of a single color.
+func WithTeams(mixed bool, teams ...[]State) Option {
+	return func(f *Four) error {
+		if len(teams) == 0 {
+			return nil
+		}
+		if len(teams) < 2 {
+			return errors.New("invalid teams, at least 2 expected")
+		}
+		seen := map[State]bool{}
+		size := 0
+		for i, team := range teams {
+			if len(team) == 0 {
+				return errors.Errorf("invalid teams, team %d is empty", i+1)
+			}
+			for _, p := range team {
+				if !f.isPlayer(p) || seen[p] {
+					return errors.Errorf("invalid teams, unexpected player %d (%s)", p, p)
+				}
+				seen[p] = true
+			}
+			if len(team) > size {
+				size = len(team)
+			}
+		}
+		if len(seen) != f.NPlayers {
+			return errors.Errorf("invalid teams, %d players out of %d", len(seen), f.NPlayers)
+		}
+
+		// Interleave the teams for the turn order.
+		players := make([]State, 0, f.NPlayers)
+		for i := 0; i < size; i++ {
+			for _, team := range teams {
+				if i < len(team) {
+					players = append(players, team[i])
+				}
+			}
+		}
+		f.AvailablePlayers = players
+		f.CurPlayerIdx, f.CurPlayer = 0, players[0]
+		f.Teams = teams
+		f.MixedTeams = mixed
+		return nil
+	}
+}
+
+// Team returns the players of the team of the given player.
+// Without teams, each player plays alone.
+func (f *Four) Team(p State) []State {
+	for _, team := range f.Teams {
+		for _, elem := range team {
+			if elem == p {
+				return team
+			}
+		}
+	}
+	return []State{p}
+}
+
+// SameTeam returns true if both players are on the same team.
+func (f *Four) SameTeam(a, b State) bool {
+	if a == b {
+		return true
+	}
+	for _, team := range f.Teams {
+		n := 0
+		for _, p := range team {
+			if p == a || p == b {
+				n++
+			}
+		}
+		if n == 2 {
+			return true
+		}
+	}
+	return false
+}
+
+// side returns the state to match when looking for lines: the first player
+// of the team when lines may mix the team colors, the given state otherwise.
+func (f *Four) side(s State) State {
+	if !f.MixedTeams || s == Empty || s == Blocker {
+		return s
+	}
+	return f.Team(s)[0]
+}
+
+// TeamLines returns the team line mode, TeamLinesMixed or TeamLinesColor.
+func (f *Four) TeamLines() string {
+	if f.MixedTeams {
+		return TeamLinesMixed
+	}
+	return TeamLinesColor
+}
+
+// sides returns the number of sides playing, teams or players.
+func (f *Four) sides() int {
+	if len(f.Teams) != 0 {
+		return len(f.Teams)
+	}
+	return f.NPlayers
+}
+
+// ParseTeams parses teams of 1 indexed players, '+' separated, the teams
+// being comma separated, e.g. "1+3,2+4".
+func ParseTeams(s string, nPlayers int) ([][]State, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var teams [][]State
+	for _, elem := range strings.Split(s, ",") {
+		var team []State
+		for _, p := range strings.Split(elem, "+") {
+			n, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil || n < 1 || n > nPlayers {
+				return nil, errors.Errorf("invalid team %q, expected players between 1 and %d", elem, nPlayers)
+			}
+			team = append(team, AvailablePlayers[n-1])
+		}
+		teams = append(teams, team)
+	}
+	return teams, nil
+}
+
+// FormatTeams is the inverse of ParseTeams.
+func FormatTeams(teams [][]State) string {
+	elems := make([]string, 0, len(teams))
+	for _, team := range teams {
+		players := make([]string, 0, len(team))
+		for _, p := range team {
+			players = append(players, strconv.Itoa(int(p)))
+		}
+		elems = append(elems, strings.Join(players, "+"))
+	}
+	return strings.Join(elems, ",")
+}
@@ -0,0 +1,150 @@
+package engine
+
+// Threats is the tactical summary of a position, see Four.Threats.
+type Threats struct {
+	Wins   map[State][]Move // Moves winning right away, for each player still in the game.
+	Blocks []Move           // Moves of the current player taking a cell an opponent would win on.
+	Gifts  []Move           // Drops of the current player letting an opponent win on top of them.
+
+	// With two players and gravity, the empty cells completing a line for
+	// each player, split by the parity of their row counted from the bottom.
+	// The first player is usually after odd threats, the second one after even ones.
+	Odd  map[State][]Cell
+	Even map[State][]Cell
+}
+
+// Threats analyzes the current position. Empty once the game is over.
+func (f *Four) Threats() Threats {
+	t := Threats{Wins: map[State][]Move{}}
+	if f.GridState != Empty {
+		return t
+	}
+	g := f.Clone()
+	for _, p := range g.AvailablePlayers {
+		if g.Rank(p) == 0 {
+			t.Wins[p] = g.winsFor(p)
+		}
+	}
+
+	// Block the opponents' winning cells, pops can not be blocked.
+	cur := f.CurPlayer
+	for p, moves := range t.Wins {
+		if g.SameTeam(p, cur) {
+			continue
+		}
+		for _, m := range moves {
+			b := Move{Player: cur, Column: m.Column, Row: m.Row}
+			if m.Pop || hasMove(t.Blocks, b) || g.Validate(b) != nil {
+				continue
+			}
+			t.Blocks = append(t.Blocks, b)
+		}
+	}
+
+	if g.FreePlacement() {
+		return t
+	}
+	for _, m := range g.Rules.LegalMoves(g) {
+		if m.Pop {
+			continue
+		}
+		if ret, _, _ := g.Play(m); ret != Empty {
+			_, _ = g.Undo()
+			continue
+		}
+		for _, p := range g.AvailablePlayers {
+			if g.Rank(p) != 0 || g.SameTeam(p, cur) {
+				continue
+			}
+			if g.wins(p, Move{Player: p, Column: m.Column}) {
+				t.Gifts = append(t.Gifts, g.History[len(g.History)-1])
+				break
+			}
+		}
+		_, _ = g.Undo()
+	}
+
+	if g.NPlayers == 2 {
+		t.Odd, t.Even = g.parityThreats()
+	}
+	return t
+}
+
+// winsFor returns the moves winning right away for the given player, as if it was their turn.
+func (f *Four) winsFor(p State) []Move {
+	cur := f.CurPlayer
+	f.setTurn(p)
+	defer f.setTurn(cur)
+
+	var moves []Move
+	for _, m := range f.Rules.LegalMoves(f) {
+		if f.wins(p, m) {
+			moves = append(moves, m)
+		}
+	}
+	return moves
+}
+
+// wins returns true if playing the given move, as player p, wins the game or ranks p.
+// The move is taken back and the turn restored.
+func (f *Four) wins(p State, m Move) bool {
+	cur := f.CurPlayer
+	f.setTurn(p)
+	defer f.setTurn(cur)
+
+	ret, _, err := f.Play(m)
+	if err != nil {
+		return false
+	}
+	won := f.Rank(p) != 0 || ret != Empty && ret != Stale && f.SameTeam(ret, p)
+	_, _ = f.Undo()
+	return won
+}
+
+// setTurn gives the turn to the given player.
+func (f *Four) setTurn(p State) {
+	for i, elem := range f.AvailablePlayers {
+		if elem == p {
+			f.CurPlayerIdx = i
+			break
+		}
+	}
+	f.CurPlayer = p
+}
+
+// parityThreats returns the empty cells, still in reach, completing a line for
+// each player, split by the parity of their row counted from the bottom.
+func (f *Four) parityThreats() (odd, even map[State][]Cell) {
+	odd, even = map[State][]Cell{}, map[State][]Cell{}
+	for x := 0; x < f.Rows; x++ {
+		for y := 0; y < f.Columns; y++ {
+			if !f.open(x, y) {
+				continue
+			}
+			for _, p := range f.AvailablePlayers {
+				f.Set(x, y, p)
+				lines := f.LinesFrom(x, y)
+				f.Set(x, y, Empty)
+				if len(lines) == 0 {
+					continue
+				}
+				if (f.Rows-x)%2 == 1 {
+					odd[p] = append(odd[p], Cell{Row: x, Col: y})
+				} else {
+					even[p] = append(even[p], Cell{Row: x, Col: y})
+				}
+			}
+		}
+	}
+	return odd, even
+}
+
+// hasMove returns true if moves holds a move on the same cell as m.
+func hasMove(moves []Move, m Move) bool {
+	for _, elem := range moves {
+		if elem.Column == m.Column && elem.Row == m.Row && elem.Pop == m.Pop {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+// columns returns the columns of the given moves.
+func columns(moves []Move) []int {
+	ret := []int{}
+	for _, m := range moves {
+		ret = append(ret, m.Column)
+	}
+	return ret
+}
+
+// TestThreats checks the threats of fixed positions.
+func TestThreats(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		cols   []int
+		wins   map[State][]int // Winning columns by player.
+		blocks []int
+		gifts  []int
+		odd    map[State][]Cell
+		even   map[State][]Cell
+	}{
+		{
+			name:   "vertical three",
+			cols:   []int{0, 6, 0, 6, 0}, // Yellow to move.
+			wins:   map[State][]int{Red: {0}, Yellow: {}},
+			blocks: []int{0},
+			gifts:  []int{},
+			odd:    map[State][]Cell{},
+			even:   map[State][]Cell{Red: {{Row: 2, Col: 0}}},
+		},
+		{
+			name:   "bottom three",
+			cols:   []int{0, 0, 1, 1, 2}, // Yellow to move.
+			wins:   map[State][]int{Red: {3}, Yellow: {}},
+			blocks: []int{3},
+			gifts:  []int{},
+			odd:    map[State][]Cell{Red: {{Row: 5, Col: 3}}},
+			even:   map[State][]Cell{},
+		},
+		{
+			name:   "three on the second row",
+			cols:   []int{2, 1, 1, 3, 3, 6, 2, 6, 5}, // Yellow to move.
+			wins:   map[State][]int{Red: {}, Yellow: {}},
+			blocks: []int{},
+			gifts:  []int{0, 4},
+			odd:    map[State][]Cell{},
+			even:   map[State][]Cell{Red: {{Row: 4, Col: 0}, {Row: 4, Col: 4}}},
+		},
+		{
+			name:   "both threaten",
+			cols:   []int{0, 6, 0, 6, 0, 6}, // Red to move.
+			wins:   map[State][]int{Red: {0}, Yellow: {6}},
+			blocks: []int{6},
+			gifts:  []int{},
+			odd:    map[State][]Cell{},
+			even:   map[State][]Cell{Red: {{Row: 2, Col: 0}}, Yellow: {{Row: 2, Col: 6}}},
+		},
+	} {
+		f, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, col := range tc.cols {
+			if _, _, err := f.PlayerMove(f.CurPlayer, col); err != nil {
+				t.Fatal(err)
+			}
+		}
+		before := takeSnapshot(f)
+		th := f.Threats()
+		if !reflect.DeepEqual(takeSnapshot(f), before) {
+			t.Fatalf("[%s] Unexpected change of the game while looking for threats", tc.name)
+		}
+
+		wins := map[State][]int{}
+		for p, moves := range th.Wins {
+			wins[p] = columns(moves)
+		}
+		if !reflect.DeepEqual(wins, tc.wins) {
+			t.Fatalf("[%s] Unexpected winning columns.\nExpected:\t%v\nGot:\t\t%v", tc.name, tc.wins, wins)
+		}
+		for _, m := range th.Blocks {
+			if m.Player != f.CurPlayer {
+				t.Fatalf("[%s] Unexpected block for player %d, %d is to move", tc.name, m.Player, f.CurPlayer)
+			}
+		}
+		if got := columns(th.Blocks); !reflect.DeepEqual(got, tc.blocks) {
+			t.Fatalf("[%s] Unexpected blocks.\nExpected:\t%v\nGot:\t\t%v", tc.name, tc.blocks, got)
+		}
+		if got := columns(th.Gifts); !reflect.DeepEqual(got, tc.gifts) {
+			t.Fatalf("[%s] Unexpected gifts.\nExpected:\t%v\nGot:\t\t%v", tc.name, tc.gifts, got)
+		}
+		if !reflect.DeepEqual(th.Odd, tc.odd) || !reflect.DeepEqual(th.Even, tc.even) {
+			t.Fatalf("[%s] Unexpected parity threats.\nExpected:\todd %v, even %v\nGot:\t\todd %v, even %v", tc.name, tc.odd, tc.even, th.Odd, th.Even)
+		}
+	}
+}
+
+// TestThreatsFree checks the blocks of free placement keep their row, and the
+// threats of a finished game are empty.
+func TestThreatsFree(t *testing.T) {
+	f, err := NewConnectFour(3, 3, DefaultNPlayers, 3, WithVariant("free"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []Move{{Column: 1, Row: 1}, {Column: 0, Row: 0}, {Column: 2, Row: 0}} {
+		m.Player = f.CurPlayer
+		if _, _, err := f.Play(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	th := f.Threats()
+	if expect := []Move{{Player: Yellow, Column: 0, Row: 2}}; !reflect.DeepEqual(th.Blocks, expect) {
+		t.Fatalf("Unexpected blocks.\nExpected:\t%+v\nGot:\t\t%+v", expect, th.Blocks)
+	}
+	if th.Gifts != nil || th.Odd != nil || th.Even != nil {
+		t.Fatalf("Unexpected gravity threats in free placement: %+v", th)
+	}
+
+	if _, _, err := f.Play(Move{Player: Yellow, Column: 1, Row: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.Play(Move{Player: Red, Column: 0, Row: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if th := f.Threats(); f.GridState != Red || len(th.Wins) != 0 || th.Blocks != nil {
+		t.Fatalf("Unexpected threats of a finished game: %+v", th)
+	}
+}
@@ -0,0 +1,67 @@
+package engine
+
+// Bound tells how a stored score relates to the exact value of the position.
+type Bound uint8
+
+// Bound values.
+const (
+	NoBound Bound = iota // Empty entry.
+	Exact
+	Lower // The position is worth at least Score.
+	Upper // The position is worth at most Score.
+)
+
+// Entry is a search result stored in a TranspositionTable.
+type Entry struct {
+	Key   uint64 // Hash of the position, see Four.Hash.
+	Score int
+	Depth int // Remaining depth the score was searched at.
+	Move  int // Best move, as an index in the move list of the caller, -1 if unknown.
+	Bound Bound
+}
+
+// TranspositionTable is a fixed size cache of search results indexed by position hash.
+// When two positions compete for the same slot, the newest one replaces the
+// oldest, but a result for the same position is only replaced by a deeper one.
+// Not safe for concurrent use.
+type TranspositionTable struct {
+	entries []Entry
+	mask    uint64
+}
+
+// NewTranspositionTable instantiates a table with room for size entries, rounded down to a power of 2.
+func NewTranspositionTable(size int) *TranspositionTable {
+	n := 1
+	for n*2 <= size {
+		n *= 2
+	}
+	return &TranspositionTable{
+		entries: make([]Entry, n),
+		mask:    uint64(n - 1),
+	}
+}
+
+// Get looks up the given position.
+func (t *TranspositionTable) Get(key uint64) (Entry, bool) {
+	e := t.entries[key&t.mask]
+	if e.Bound == NoBound || e.Key != key {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+// Put stores the given entry, following the replacement scheme.
+func (t *TranspositionTable) Put(e Entry) {
+	slot := &t.entries[e.Key&t.mask]
+	if slot.Bound != NoBound && slot.Key == e.Key && slot.Depth > e.Depth {
+		return
+	}
+	*slot = e
+}
+
+// Clear empties the table.
+func (t *TranspositionTable) Clear() {
+	for i := range t.entries {
+		t.entries[i] = Entry{}
+	}
+}
@@ -0,0 +1,46 @@
+package engine
+
+import "testing"
+
+func TestTranspositionTable(t *testing.T) {
+	tt := NewTranspositionTable(1000)
+	if expect, got := 512, len(tt.entries); got != expect {
+		t.Fatalf("Unexpected table size.\nExpected:\t%d\nGot:\t\t%d", expect, got)
+	}
+	if _, ok := tt.Get(42); ok {
+		t.Fatal("Unexpected entry in an empty table")
+	}
+
+	tt.Put(Entry{Key: 42, Score: 1, Depth: 3, Move: 2, Bound: Exact})
+	if e, ok := tt.Get(42); !ok || e.Score != 1 || e.Move != 2 {
+		t.Fatalf("Unexpected entry: %+v, %t", e, ok)
+	}
+	// Same slot, different position.
+	if _, ok := tt.Get(42 + 512); ok {
+		t.Fatal("Unexpected entry for a colliding position")
+	}
+
+	// A shallower result for the same position is ignored.
+	tt.Put(Entry{Key: 42, Score: 2, Depth: 1, Move: 0, Bound: Lower})
+	if e, _ := tt.Get(42); e.Score != 1 {
+		t.Fatalf("Unexpected shallower entry: %+v", e)
+	}
+	tt.Put(Entry{Key: 42, Score: 3, Depth: 5, Move: 1, Bound: Upper})
+	if e, _ := tt.Get(42); e.Score != 3 || e.Bound != Upper {
+		t.Fatalf("Unexpected entry after a deeper result: %+v", e)
+	}
+
+	// Another position replaces the slot, whatever the depth.
+	tt.Put(Entry{Key: 42 + 512, Score: 4, Depth: 1, Bound: Exact})
+	if _, ok := tt.Get(42); ok {
+		t.Fatal("Unexpected replaced entry")
+	}
+	if e, ok := tt.Get(42 + 512); !ok || e.Score != 4 {
+		t.Fatalf("Unexpected entry: %+v, %t", e, ok)
+	}
+
+	tt.Clear()
+	if _, ok := tt.Get(42 + 512); ok {
+		t.Fatal("Unexpected entry after clear")
+	}
+}
@@ -0,0 +1,74 @@
+package engine
+
+import "sync"
+
+// zobrist holds the random keys used to hash the positions of a game configuration.
+type zobrist struct {
+	base  uint64          // Key of the configuration itself.
+	cells [][Stale]uint64 // Keys for each cell and state, indexed by row*columns+col.
+	turn  []uint64        // Keys for each player index.
+}
+
+// zobristCacheSize is the number of configurations whose keys are kept.
+const zobristCacheSize = 16
+
+// Keys are generated once per configuration and shared by the games created
+// meanwhile. Only the latest configurations are kept, the keys of the others
+// are generated again, the same, when needed.
+var (
+	zobristMu     sync.Mutex
+	zobristTables = map[[4]int]*zobrist{}
+	zobristOrder  [][4]int // Configurations of zobristTables, oldest first.
+)
+
+// splitmix64 is a small deterministic generator, so hashes are stable across runs.
+func splitmix64(x *uint64) uint64 {
+	*x += 0x9e3779b97f4a7c15
+	z := *x
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// zobristFor returns the keys for the given configuration.
+// The configuration seeds the generator so different configurations never share keys.
+func zobristFor(columns, rows, nWin, nPlayers int) *zobrist {
+	conf := [4]int{columns, rows, nWin, nPlayers}
+
+	zobristMu.Lock()
+	defer zobristMu.Unlock()
+
+	if z, ok := zobristTables[conf]; ok {
+		return z
+	}
+	var seed uint64
+	for _, n := range conf {
+		seed = splitmix64(&seed) ^ uint64(n)
+	}
+	z := &zobrist{
+		base:  splitmix64(&seed),
+		cells: make([][Stale]uint64, columns*rows),
+		turn:  make([]uint64, nPlayers),
+	}
+	for i := range z.cells {
+		for j := range z.cells[i] {
+			z.cells[i][j] = splitmix64(&seed)
+		}
+	}
+	for i := range z.turn {
+		z.turn[i] = splitmix64(&seed)
+	}
+	if len(zobristOrder) == zobristCacheSize {
+		delete(zobristTables, zobristOrder[0])
+		zobristOrder = zobristOrder[1:]
+	}
+	zobristTables[conf] = z
+	zobristOrder = append(zobristOrder, conf)
+	return z
+}
+
+// Hash returns the Zobrist hash of the current position, including the player to move.
+// It is updated incrementally with each move and undo.
+func (f *Four) Hash() uint64 {
+	return f.hash ^ f.zobrist.turn[f.CurPlayerIdx]
+}
@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// fullHash computes the hash of the game from scratch.
+func fullHash(f *Four) uint64 {
+	h := f.zobrist.base
+	for x := range f.Content {
+		for y, s := range f.Content[x] {
+			if s != Empty && s != Blocker {
+				h ^= f.zobrist.cells[x*f.Columns+y][s]
+			}
+		}
+	}
+	return h ^ f.zobrist.turn[f.CurPlayerIdx]
+}
+
+// TestHashStable checks the keys do not change across runs, the hashes may be stored.
+func TestHashStable(t *testing.T) {
+	f, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect, got := uint64(0x9fb0152a50a47ae9), f.Hash(); got != expect {
+		t.Fatalf("Unexpected hash of the empty grid.\nExpected:\t%#x\nGot:\t\t%#x", expect, got)
+	}
+	if _, _, err := f.PlayerMove(Red, 3); err != nil {
+		t.Fatal(err)
+	}
+	if expect, got := uint64(0x44a477020357b38f), f.Hash(); got != expect {
+		t.Fatalf("Unexpected hash after the first move.\nExpected:\t%#x\nGot:\t\t%#x", expect, got)
+	}
+}
+
+// TestHashUndoRedo checks the incremental hash against a full computation
+// while playing, taking back and replaying random moves.
+func TestHashUndoRedo(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, variant := range []string{"classic", "popout", "free"} {
+		for n := 0; n < 200; n++ {
+			f, err := NewConnectFour(2+rnd.Intn(9), 2+rnd.Intn(9), 1+rnd.Intn(4), 2+rnd.Intn(4), WithVariant(variant))
+			if err != nil {
+				continue
+			}
+			var hashes []uint64 // Hash after each move.
+			for i := 0; i < 100; i++ {
+				switch rnd.Intn(4) {
+				case 0:
+					if _, err := f.Undo(); err == nil {
+						hashes = hashes[:len(hashes)-1]
+					}
+				case 1:
+					if _, _, err := f.Redo(); err == nil {
+						hashes = append(hashes, f.Hash())
+					}
+				default:
+					moves := f.Rules.LegalMoves(f)
+					if f.GridState != Empty || len(moves) == 0 {
+						continue
+					}
+					if _, _, err := f.Play(moves[rnd.Intn(len(moves))]); err != nil {
+						t.Fatal(err)
+					}
+					hashes = append(hashes, f.Hash())
+				}
+				if expect, got := fullHash(f), f.Hash(); got != expect {
+					t.Fatalf("[%s] Unexpected hash.\nExpected:\t%#x\nGot:\t\t%#x", variant, expect, got)
+				}
+			}
+			// Taking back the moves restores the previous hashes.
+			for i := len(hashes) - 2; i >= 0; i-- {
+				if _, err := f.Undo(); err != nil {
+					t.Fatal(err)
+				}
+				if got := f.Hash(); got != hashes[i] {
+					t.Fatalf("[%s] Unexpected hash after undo.\nExpected:\t%#x\nGot:\t\t%#x", variant, hashes[i], got)
+				}
+			}
+		}
+	}
+}
+
+func TestHashTransposition(t *testing.T) {
+	play := func(cols ...int) *Four {
+		f, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, col := range cols {
+			if _, _, err := f.PlayerMove(f.CurPlayer, col); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return f
+	}
+	if a, b := play(0, 1, 2, 3), play(2, 3, 0, 1); a.Hash() != b.Hash() {
+		t.Fatalf("Unexpected hashes for the same position.\nExpected:\t%#x\nGot:\t\t%#x", a.Hash(), b.Hash())
+	}
+	// Same pieces, different player to move.
+	if a, b := play(0, 1, 2), play(2, 1, 0); a.Hash() != b.Hash() {
+		t.Fatalf("Unexpected hashes for the same position.\nExpected:\t%#x\nGot:\t\t%#x", a.Hash(), b.Hash())
+	}
+	// Same cells, different owners.
+	if a, b := play(0, 1), play(1, 0); a.Hash() == b.Hash() {
+		t.Fatal("Unexpected same hash for different positions")
+	}
+	// Same grid, different configurations.
+	a, _ := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, 4)
+	b, _ := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, 5)
+	if a.Hash() == b.Hash() {
+		t.Fatal("Unexpected same hash for different configurations")
+	}
+}
+
+// TestHashSaveLoad checks the hashes survive the notation and the archive.
+func TestHashSaveLoad(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, opts := range [][]Option{
+		nil,
+		{WithVariant("popout")},
+		{WithVariant("free")},
+		{WithBlockers(Cell{Row: 5, Col: 3})},
+	} {
+		for n := 0; n < 50; n++ {
+			f, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			randomGame(t, f, rnd, rnd.Intn(30), nil)
+
+			g, err := ParseNotation(f.Notation())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g.Hash() != f.Hash() {
+				t.Fatalf("Unexpected hash after loading %s.\nExpected:\t%#x\nGot:\t\t%#x", f.Notation(), f.Hash(), g.Hash())
+			}
+			for i, m := range g.History {
+				if m.hash != f.History[i].hash {
+					t.Fatalf("Unexpected hash of move %d after loading %s", i+1, f.Notation())
+				}
+			}
+
+			buf := bytes.NewBuffer(nil)
+			if err := NewArchive(f, "test").Encode(buf); err != nil {
+				t.Fatal(err)
+			}
+			_, g, err = DecodeArchive(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g.Hash() != f.Hash() {
+				t.Fatalf("Unexpected hash after decoding the archive of %s.\nExpected:\t%#x\nGot:\t\t%#x", f.Notation(), f.Hash(), g.Hash())
+			}
+		}
+	}
+}
+
+// TestHashCache checks the keys of the configurations dropped from the cache
+// are generated again the same.
+func TestHashCache(t *testing.T) {
+	f, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.PlayerMove(Red, 3); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2*zobristCacheSize; i++ {
+		if _, err := NewConnectFour(DefaultCols+1+i, DefaultRows, DefaultNPlayers, DefaultNWin); err != nil {
+			t.Fatal(err)
+		}
+	}
+	zobristMu.Lock()
+	n, cached := len(zobristTables), zobristTables[[4]int{DefaultCols, DefaultRows, DefaultNWin, DefaultNPlayers}]
+	zobristMu.Unlock()
+	if n > zobristCacheSize || cached != nil {
+		t.Fatalf("Unexpected cache of %d configurations, holding the first one: %t", n, cached != nil)
+	}
+
+	g, err := NewConnectFour(DefaultCols, DefaultRows, DefaultNPlayers, DefaultNWin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := g.PlayerMove(Red, 3); err != nil {
+		t.Fatal(err)
+	}
+	if g.zobrist == f.zobrist || g.Hash() != f.Hash() {
+		t.Fatalf("Unexpected hash after generating the keys again.\nExpected:\t%#x\nGot:\t\t%#x", f.Hash(), g.Hash())
+	}
+}
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/creack/gofour/engine"
+	"github.com/creack/gofour/engine/ai"
+	"github.com/creack/gofour/engine/solver"
 	"github.com/creack/gofour/runtime"
 
 	// Load runtimes.
+	_ "github.com/creack/gofour/runtime/analyze"
 	_ "github.com/creack/gofour/runtime/server"
 	_ "github.com/creack/gofour/runtime/terminal"
 	_ "github.com/creack/gofour/runtime/text"
@@ -20,19 +28,89 @@ func main() {
 		rows     = flag.Int("rows", engine.DefaultRows, "number of rows")
 		nPlayers = flag.Int("p", engine.DefaultNPlayers, fmt.Sprintf("number of players. (max: %d)", len(engine.AvailablePlayers)))
 		nWin     = flag.Int("w", engine.DefaultNWin, "number of consecutive color to win")
-		mode     = flag.String("mode", engine.DefaultMode, "Game mode. Values: [terminal, text]")
+		mode     = flag.String("mode", engine.DefaultMode, "Game mode. Values: [terminal, text, server, analyze]")
+		aiSeats  = flag.String("ai", "", "computer players, <player>:<level> comma separated, e.g. 2:hard. Levels: [easy, medium, hard, mcts]")
+		book     = flag.String("book", "", "opening book file for the solver")
+		load     = flag.String("load", "", "game record file to resume, overrides the grid flags. JSON archive if ending with .json")
+		variant  = flag.String("variant", engine.DefaultVariant, "Game rules. Values: [classic, popout, free, cylinder]")
+		save     = flag.String("save", "", "file to save the game record to when exiting. JSON archive if ending with .json")
+		layout   = flag.String("layout", "", "grid layout file, '.' for a cell and '#' for a blocker, one line per row. Overrides the grid size flags")
+		blockers = flag.Int("blockers", 0, "number of blockers randomly placed on the grid")
+		seed     = flag.Int64("seed", 0, "seed for the random blockers, 0 for a random one")
+		teams    = flag.String("teams", "", "players playing together, '+' separated, teams comma separated, e.g. 1+3,2+4")
+		lines    = flag.String("team-lines", engine.TeamLinesMixed, "lines winning for a team. Values: [mixed, color]")
+		ranking  = flag.Bool("ranking", false, "play on once a player wins, until everyone is ranked")
 	)
 	flag.Parse()
 
+	if *book != "" {
+		if err := solver.Default().LoadBookFile(*book); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	run, exists := runtime.Runtimes[*mode]
 	if !exists {
 		log.Fatalf("%s is not a valid runtime.", *mode)
 	}
 
-	four, err := engine.NewConnectFour(*cols, *rows, *nPlayers, *nWin)
+	var four *engine.Four
+	if *load != "" {
+		buf, err := ioutil.ReadFile(*load)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if strings.HasSuffix(*load, ".json") {
+			_, four, err = engine.DecodeArchive(bytes.NewReader(buf))
+		} else {
+			four, err = engine.ParseNotation(string(buf))
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		l := &engine.Layout{Columns: *cols, Rows: *rows}
+		if *layout != "" {
+			file, err := os.Open(*layout)
+			if err != nil {
+				log.Fatal(err)
+			}
+			l, err = engine.ParseLayout(file)
+			_ = file.Close() // Best effort.
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else if *blockers != 0 {
+			if *seed == 0 {
+				*seed = time.Now().UnixNano()
+			}
+			var err error
+			if l, err = engine.RandomLayout(*cols, *rows, *blockers, *seed); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *lines != engine.TeamLinesMixed && *lines != engine.TeamLinesColor {
+			log.Fatalf("%s is not a valid team line mode.", *lines)
+		}
+		t, err := engine.ParseTeams(*teams, *nPlayers)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts := []engine.Option{engine.WithVariant(*variant), engine.WithBlockers(l.Blockers...), engine.WithTeams(*lines == engine.TeamLinesMixed, t...)}
+		if *ranking {
+			opts = append(opts, engine.WithRanking())
+		}
+		if four, err = engine.NewConnectFour(l.Columns, l.Rows, *nPlayers, *nWin, opts...); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	bots, err := ai.ParseSeats(*aiSeats, four.NPlayers)
 	if err != nil {
 		log.Fatal(err)
 	}
+	runtime.Bots = bots
+	runtime.Variant = four.Variant
 
 	if err := run.Init(four); err != nil {
 		log.Fatal(err)
@@ -42,4 +120,17 @@ func main() {
 	if err := run.Run(); err != nil {
 		log.Fatal(err)
 	}
+
+	if *save != "" {
+		buf := bytes.NewBufferString(four.Notation() + "\n")
+		if strings.HasSuffix(*save, ".json") {
+			buf.Reset()
+			if err := engine.NewArchive(four, *mode).Encode(buf); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err := ioutil.WriteFile(*save, buf.Bytes(), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
 }
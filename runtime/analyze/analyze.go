@@ -0,0 +1,87 @@
+// Package analyze is a runtime solving positions of the default game.
+package analyze
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/creack/gofour/engine"
+	"github.com/creack/gofour/engine/solver"
+	"github.com/creack/gofour/runtime"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	runtime.Runtimes["analyze"] = &Runtime{}
+}
+
+// Runtime reads positions from stdin, one per line as the sequence of 1 indexed
+// columns played, and prints for each one:
+//
+//	<moves> <score> <best column> <plies to the end>
+//
+// See the solver package for the score convention. The output is a valid opening book.
+type Runtime struct {
+	four *engine.Four
+}
+
+// Init setup the runtime.
+func (r *Runtime) Init(four *engine.Four) error {
+	r.four = four
+	return nil
+}
+
+// Run analyzes the loaded game, if any, then the positions until stdin is closed.
+func (r *Runtime) Run() error {
+	if len(r.four.History) != 0 {
+		moves := ""
+		for _, m := range r.four.History {
+			moves += string(rune('1' + m.Column))
+		}
+		if err := report(moves, r.four); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", moves, err)
+		}
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		moves := strings.TrimSpace(scanner.Text())
+		if moves == "" || moves[0] == '#' {
+			continue
+		}
+		if err := r.analyze(moves); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", moves, err)
+		}
+	}
+	return errors.Wrap(scanner.Err(), "error reading positions")
+}
+
+// analyze solves and prints the given position.
+func (r *Runtime) analyze(moves string) error {
+	f, err := r.four.Reset()
+	if err != nil {
+		return err
+	}
+	for _, c := range moves {
+		if _, _, err := f.PlayerMove(f.CurPlayer, int(c-'1')); err != nil {
+			return err
+		}
+	}
+	return report(moves, f)
+}
+
+// report solves and prints the position of f, reached with the given moves.
+func report(moves string, f *engine.Four) error {
+	score, col, err := solver.Solve(f)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %d %d %d\n", moves, score, col+1, solver.Distance(len(f.History), score))
+	return nil
+}
+
+// Close is a no op.
+func (r *Runtime) Close() error {
+	return nil
+}
@@ -1,6 +1,12 @@
 package runtime
 
-import "github.com/creack/gofour/engine"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/creack/gofour/engine"
+	"github.com/creack/gofour/engine/ai"
+)
 
 // FourRuntime is the interface to run connect four.
 type FourRuntime interface {
@@ -11,3 +17,99 @@ type FourRuntime interface {
 
 // Runtimes holds the registered runtimes.
 var Runtimes = map[string]FourRuntime{}
+
+// Bots holds the computer players by seat.
+var Bots = map[engine.State]ai.Bot{}
+
+// Variant is the default variant for the games created by the runtimes.
+var Variant = engine.DefaultVariant
+
+// TakeBack undoes the last human move along with the computer moves played after it.
+// Returns the moves taken back, most recent first.
+func TakeBack(f *engine.Four, bots map[engine.State]ai.Bot) ([]engine.Move, error) {
+	var moves []engine.Move
+	for {
+		m, err := f.Undo()
+		if err != nil {
+			if len(moves) != 0 {
+				return moves, nil
+			}
+			return nil, err
+		}
+		moves = append(moves, m)
+		if _, ok := bots[m.Player]; !ok {
+			return moves, nil
+		}
+	}
+}
+
+// Winner describes the winner of the finished game, e.g. "Player 1 (●)",
+// or with teams "Team 1 (●) + 3 (●)".
+func Winner(f *engine.Four) string {
+	if len(f.Teams) == 0 {
+		return fmt.Sprintf("Player %d (%s)", f.GridState, f.GridState)
+	}
+	players := []string{}
+	for _, p := range f.Team(f.GridState) {
+		players = append(players, fmt.Sprintf("%d (%s)", p, p))
+	}
+	return "Team " + strings.Join(players, " + ")
+}
+
+// Standings lists the ranked players, e.g. "1st Player 3 (●), 2nd Player 1 (●)".
+// Once the game is over, the players left unranked share the next place.
+func Standings(f *engine.Four) string {
+	places := []string{}
+	for i, p := range f.Placements {
+		places = append(places, fmt.Sprintf("%s Player %d (%s)", ordinal(i+1), p, p))
+	}
+	if f.GridState != engine.Empty {
+		tied := []string{}
+		for _, p := range f.AvailablePlayers {
+			if f.Rank(p) == 0 {
+				tied = append(tied, fmt.Sprintf("Player %d (%s)", p, p))
+			}
+		}
+		if len(tied) != 0 {
+			places = append(places, fmt.Sprintf("%s %s", ordinal(len(f.Placements)+1), strings.Join(tied, " and ")))
+		}
+	}
+	return strings.Join(places, ", ")
+}
+
+// LiveWindows lists, for each player still in the game, how many lines they
+// can still complete, e.g. "● 12, ● 9".
+func LiveWindows(f *engine.Four) string {
+	counts := []string{}
+	for _, p := range f.AvailablePlayers {
+		if f.Rank(p) == 0 {
+			counts = append(counts, fmt.Sprintf("%s %d", p, f.LiveWindows(p)))
+		}
+	}
+	return strings.Join(counts, ", ")
+}
+
+// ordinal returns the English ordinal of n, e.g. "2nd".
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+// CanPop returns true if the current player is allowed to pop a piece.
+func CanPop(f *engine.Four) bool {
+	for _, m := range f.Rules.LegalMoves(f) {
+		if m.Pop {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/creack/ehttp"
+	"github.com/creack/gofour/engine"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Event types sent on the live game socket and stream.
+const (
+	EventSnapshot     = "snapshot"      // Whole game, sent once on connection.
+	EventPlayerJoined = "player_joined" // A player took a seat.
+	EventMovePlayed   = "move_played"   // A move was played, by a player or a computer.
+	EventTakeBack     = "take_back"     // Moves were taken back.
+	EventGameOver     = "game_over"     // The game is won, drawn or resigned.
+	EventChat         = "chat"          // A player sent a message.
+	EventError        = "error"         // A message of the client failed, sent to that client only.
+)
+
+const (
+	eventBuffer  = 64   // Number of events a listener may lag behind before being dropped.
+	eventLogSize = 4096 // Number of events kept for the listeners resuming a stream.
+)
+
+// Event is a message of the live game socket and stream. Only the fields of its type are set.
+type Event struct {
+	ID           uint64         `json:"id,omitempty"` // Increasing, from 1, for the events of a game. Unset for the snapshot and errors.
+	Type         string         `json:"type"`
+	Game         *engine.Four   `json:"game,omitempty"`          // Snapshot.
+	Player       engine.State   `json:"player,omitempty"`        // Player joined.
+	PlayerName   string         `json:"player_name,omitempty"`   // Player joined.
+	Move         *engine.Move   `json:"move,omitempty"`          // Move played.
+	Moves        []engine.Move  `json:"moves,omitempty"`         // Take back, most recent first.
+	NextPlayer   engine.State   `json:"next_player,omitempty"`   // Move played and take back, while the game is running.
+	GridState    engine.State   `json:"grid_state,omitempty"`    // Game over.
+	WinningLines []engine.Line  `json:"winning_lines,omitempty"` // Game over.
+	Placements   []engine.State `json:"placements,omitempty"`    // Move played in ranking mode and game over.
+	Result       *engine.Result `json:"result,omitempty"`        // Game over on resign.
+	Chat         *ChatMessage   `json:"chat,omitempty"`          // Chat.
+	Error        *APIError      `json:"error,omitempty"`         // Error.
+}
+
+// ClientMessage is a message sent by a client on the live game socket.
+type ClientMessage struct {
+	Type    string `json:"type"`    // "move", "take_back", "resign" or "chat".
+	Column  int    `json:"col"`     // Move, as PlayMoveReq.
+	Row     int    `json:"row"`     // Move, as PlayMoveReq.
+	Action  string `json:"action"`  // Move, as PlayMoveReq.
+	Message string `json:"message"` // Chat.
+}
+
+// subscribe registers a listener for the events of the game.
+// Returns the ID of the last event published before the subscription.
+func (s *session) subscribe() (chan Event, uint64) {
+	ch := make(chan Event, eventBuffer)
+	s.Lock()
+	s.subs[ch] = true
+	lastID := s.lastID
+	s.Unlock()
+	return ch, lastID
+}
+
+// resume registers a listener for the events following the given event ID
+// and returns the events it missed. Returns false, without registering,
+// when the missed events are no longer logged or the ID is unknown.
+func (s *session) resume(lastID uint64) (chan Event, []Event, bool) {
+	s.Lock()
+	defer s.Unlock()
+	if lastID > s.lastID || s.lastID-lastID > uint64(len(s.log)) {
+		return nil, nil, false
+	}
+	missed := append([]Event(nil), s.log[len(s.log)-int(s.lastID-lastID):]...)
+	ch := make(chan Event, eventBuffer)
+	s.subs[ch] = true
+	return ch, missed, true
+}
+
+// unsubscribe removes the listener and closes its channel, if not already dropped.
+func (s *session) unsubscribe(ch chan Event) {
+	s.Lock()
+	if s.subs[ch] {
+		delete(s.subs, ch)
+		close(ch)
+	}
+	s.Unlock()
+}
+
+// publish numbers and logs the event, then sends it to the listeners of the game.
+// Listeners too slow to keep up are dropped and their channel closed.
+func (s *session) publish(e Event) {
+	s.Lock()
+	s.lastID++
+	e.ID = s.lastID
+	if s.log = append(s.log, e); len(s.log) > eventLogSize {
+		s.log = append(s.log[:0], s.log[len(s.log)-eventLogSize:]...)
+	}
+	for ch := range s.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+	s.Unlock()
+}
+
+// publishMove sends the last move of the game to its listeners, followed by
+// the end of the game when the move ends it. The play lock must be held.
+func (r *Runtime) publishMove(gameID string, game *engine.Four) {
+	s := r.session(gameID)
+	m := game.History[len(game.History)-1]
+	e := Event{Type: EventMovePlayed, Move: &m, Placements: append([]engine.State(nil), game.Placements...)}
+	if m.GridState == engine.Empty {
+		e.NextPlayer = game.CurPlayer
+	}
+	s.publish(e)
+	if m.GridState != engine.Empty {
+		s.publish(Event{Type: EventGameOver, GridState: m.GridState, WinningLines: game.WinningLines, Placements: e.Placements})
+	}
+}
+
+// errorEvent returns the error event of the given error.
+func errorEvent(err error) Event {
+	code := http.StatusInternalServerError
+	if e, ok := err.(*ehttp.Error); ok && e.Code() != 0 {
+		code = e.Code()
+	}
+	return Event{Type: EventError, Error: &APIError{Code: code, Status: http.StatusText(code), Message: err.Error()}}
+}
+
+// gameSocketV1 is the live game socket. It sends a snapshot of the game, then
+// an Event on each change, and accepts ClientMessage moves, take backs,
+// resign and chat. Acting as a player requires the token of the seat, in
+// the TokenHeader header, as a bearer Authorization header or, for browsers,
+// in the token query string. Without token, the socket is read only.
+func (r *Runtime) gameSocketV1(w http.ResponseWriter, req *http.Request, p httprouter.Params) error {
+	gameID := p.ByName("id")
+	game, err := r.game(gameID)
+	if err != nil {
+		return err
+	}
+	token := requestToken(req)
+	if token == "" {
+		token = req.URL.Query().Get("token")
+	}
+	if token != "" {
+		if _, err := r.seat(gameID, game, token, ""); err != nil {
+			return err
+		}
+	}
+	ws, err := upgradeWebSocket(w, req)
+	if err != nil {
+		return err
+	}
+	r.serveSocket(gameID, game, token, ws)
+	return nil
+}
+
+// serveSocket runs the live game socket until either side closes it.
+func (r *Runtime) serveSocket(gameID string, game *engine.Four, token string, ws *WebSocket) {
+	defer func() { _ = ws.Close() }()
+
+	// Subscribe along the snapshot so no change is missed after it.
+	s := r.session(gameID)
+	s.play.Lock()
+	events, _ := s.subscribe()
+	snapshot := game.Clone()
+	s.play.Unlock()
+	defer s.unsubscribe(events)
+	if err := ws.WriteJSON(Event{Type: EventSnapshot, Game: snapshot}); err != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			buf, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			msg := ClientMessage{Column: -1, Row: -1, Action: "drop"}
+			if err := json.Unmarshal(buf, &msg); err != nil {
+				err = ehttp.NewErrorf(http.StatusBadRequest, "invalid JSON message: %s", err)
+				if ws.WriteJSON(errorEvent(err)) != nil {
+					return
+				}
+				continue
+			}
+			if err := r.socketMessage(gameID, token, msg); err != nil {
+				if ws.WriteJSON(errorEvent(err)) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	// The pongs keep the reads of an idle client within their deadline.
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				_ = ws.closeWith(wsClosePolicy, "too slow to keep up with the game")
+				return
+			}
+			if err := ws.WriteJSON(e); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := ws.Ping(); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// socketMessage handles a message sent on the live game socket.
+// The resulting changes are sent back as events.
+func (r *Runtime) socketMessage(gameID, token string, msg ClientMessage) error {
+	var err error
+	switch msg.Type {
+	case "move":
+		_, err = r.playMove(&PlayMoveReq{GameID: gameID, Token: token, Column: msg.Column, Row: msg.Row, Action: msg.Action})
+	case "take_back":
+		_, err = r.takeBack(&TakeBackReq{GameID: gameID, Token: token})
+	case "resign":
+		_, err = r.resign(&ResignReq{GameID: gameID, Token: token})
+	case "chat":
+		_, err = r.chat(&ChatReq{GameID: gameID, Token: token, Message: msg.Message})
+	default:
+		err = ehttp.NewErrorf(http.StatusBadRequest, "unknown message type '%s'", msg.Type)
+	}
+	return err
+}
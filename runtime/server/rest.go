@@ -0,0 +1,207 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/creack/ehttp"
+	"github.com/creack/ehttp/ehttprouter"
+	"github.com/creack/gofour/engine"
+	"github.com/julienschmidt/httprouter"
+)
+
+// APIError describes a failed call to the v1 API.
+type APIError struct {
+	Code    int    `json:"code"`    // HTTP status code.
+	Status  string `json:"status"`  // HTTP status text.
+	Message string `json:"message"` // What went wrong.
+}
+
+// ErrorResp is the body of the v1 API error responses.
+type ErrorResp struct {
+	Error APIError `json:"error"`
+}
+
+// sendError is the ehttp error callback of the v1 API, the status code is already sent.
+func sendError(w ehttp.ResponseWriter, req *http.Request, err error) {
+	_ = json.NewEncoder(w).Encode(ErrorResp{Error: APIError{
+		Code:    w.Code(),
+		Status:  http.StatusText(w.Code()),
+		Message: err.Error(),
+	}})
+}
+
+// newRouter returns the router of the v1 API:
+//
+//	GET    /v1/games                 List the games.
+//	POST   /v1/games                 Create a game, CreateGameReq body.
+//	GET    /v1/games/:id             Get a game.
+//	GET    /v1/games/:id/events      Stream the game on each state change, see AttachGame.
+//	GET    /v1/games/:id/stream      Server-Sent Events of the game, resumable, see gameStreamV1.
+//	GET    /v1/games/:id/ws          Live game socket, see gameSocketV1.
+//	POST   /v1/games/:id/players     Join a game, JoinGameReq body.
+//	GET    /v1/games/:id/moves       List the moves played.
+//	POST   /v1/games/:id/moves       Play a move, PlayMoveReq body.
+//	DELETE /v1/games/:id/moves/last  Take back the last move.
+//	POST   /v1/games/:id/resign      Resign the game.
+//	GET    /v1/games/:id/chat        List the chat messages.
+//	POST   /v1/games/:id/chat        Send a chat message, ChatReq body.
+//
+// Acting as a player, moves, resign and chat, requires the token returned
+// on join, in the TokenHeader header or as a bearer Authorization header.
+// Errors are returned as ErrorResp.
+func (r *Runtime) newRouter() *ehttprouter.Router {
+	router := ehttprouter.New(sendError, "application/json; charset=utf-8", true, nil)
+	router.GET("/v1/games", r.listGamesV1)
+	router.POST("/v1/games", r.createGameV1)
+	router.GET("/v1/games/:id", r.getGameV1)
+	router.GET("/v1/games/:id/events", r.attachGameV1)
+	router.GET("/v1/games/:id/stream", r.gameStreamV1)
+	router.GET("/v1/games/:id/ws", r.gameSocketV1)
+	router.POST("/v1/games/:id/players", r.joinGameV1)
+	router.GET("/v1/games/:id/moves", r.listMovesV1)
+	router.POST("/v1/games/:id/moves", r.playMoveV1)
+	router.DELETE("/v1/games/:id/moves/last", r.takeBackV1)
+	router.POST("/v1/games/:id/resign", r.resignV1)
+	router.GET("/v1/games/:id/chat", r.listChatV1)
+	router.POST("/v1/games/:id/chat", r.chatV1)
+
+	notFound := router.MWError(func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) error {
+		return ehttp.NewErrorf(http.StatusNotFound, "no route for %s %s", req.Method, req.URL.Path)
+	})
+	router.NotFound = func(w http.ResponseWriter, req *http.Request) { notFound(w, req, nil) }
+	notAllowed := router.MWError(func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) error {
+		return ehttp.NewErrorf(http.StatusMethodNotAllowed, "method %s not allowed on %s", req.Method, req.URL.Path)
+	})
+	router.MethodNotAllowed = func(w http.ResponseWriter, req *http.Request) { notAllowed(w, req, nil) }
+	return router
+}
+
+// decodeBody decodes the JSON body of the request in v. An empty body leaves v untouched.
+func decodeBody(req *http.Request, v interface{}) error {
+	if err := json.NewDecoder(req.Body).Decode(v); err != nil && err != io.EOF {
+		return ehttp.NewErrorf(http.StatusBadRequest, "invalid JSON body: %s", err)
+	}
+	return nil
+}
+
+// writeJSON sends v as JSON with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (r *Runtime) listGamesV1(w http.ResponseWriter, req *http.Request, _ httprouter.Params) error {
+	return writeJSON(w, http.StatusOK, r.listGames())
+}
+
+func (r *Runtime) createGameV1(w http.ResponseWriter, req *http.Request, _ httprouter.Params) error {
+	data := newCreateGameReq()
+	if err := decodeBody(req, data); err != nil {
+		return err
+	}
+	gameID, game, err := r.createGame(data)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", "/v1/games/"+gameID)
+	return writeJSON(w, http.StatusCreated, gameSummary(gameID, game, r.session(gameID)))
+}
+
+func (r *Runtime) getGameV1(w http.ResponseWriter, req *http.Request, p httprouter.Params) error {
+	gameID := p.ByName("id")
+	game, err := r.game(gameID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, r.session(gameID).snapshot(game))
+}
+
+func (r *Runtime) attachGameV1(w http.ResponseWriter, req *http.Request, p httprouter.Params) error {
+	gameID := p.ByName("id")
+	game, err := r.game(gameID)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return r.attach(w, req, gameID, game)
+}
+
+func (r *Runtime) joinGameV1(w http.ResponseWriter, req *http.Request, p httprouter.Params) error {
+	data := &JoinGameReq{}
+	if err := decodeBody(req, data); err != nil {
+		return err
+	}
+	data.GameID = p.ByName("id")
+	resp, err := r.joinGame(data)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, resp)
+}
+
+func (r *Runtime) listMovesV1(w http.ResponseWriter, req *http.Request, p httprouter.Params) error {
+	gameID := p.ByName("id")
+	game, err := r.game(gameID)
+	if err != nil {
+		return err
+	}
+	s := r.session(gameID)
+	s.play.Lock()
+	moves := append([]engine.Move{}, game.History...)
+	s.play.Unlock()
+	return writeJSON(w, http.StatusOK, moves)
+}
+
+func (r *Runtime) playMoveV1(w http.ResponseWriter, req *http.Request, p httprouter.Params) error {
+	data := newPlayMoveReq()
+	if err := decodeBody(req, data); err != nil {
+		return err
+	}
+	data.GameID, data.Token = p.ByName("id"), requestToken(req)
+	game, err := r.playMove(data)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, game)
+}
+
+func (r *Runtime) takeBackV1(w http.ResponseWriter, req *http.Request, p httprouter.Params) error {
+	data := &TakeBackReq{GameID: p.ByName("id"), Token: requestToken(req), PlayerName: req.URL.Query().Get("player_name")}
+	moves, err := r.takeBack(data)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, moves)
+}
+
+func (r *Runtime) resignV1(w http.ResponseWriter, req *http.Request, p httprouter.Params) error {
+	result, err := r.resign(&ResignReq{GameID: p.ByName("id"), Token: requestToken(req)})
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, result)
+}
+
+func (r *Runtime) listChatV1(w http.ResponseWriter, req *http.Request, p httprouter.Params) error {
+	msgs, err := r.chatMessages(p.ByName("id"))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, msgs)
+}
+
+func (r *Runtime) chatV1(w http.ResponseWriter, req *http.Request, p httprouter.Params) error {
+	data := &ChatReq{}
+	if err := decodeBody(req, data); err != nil {
+		return err
+	}
+	data.GameID, data.Token = p.ByName("id"), requestToken(req)
+	msg, err := r.chat(data)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, msg)
+}
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/creack/ehttp"
 	"github.com/creack/gofour/engine"
+	"github.com/creack/gofour/engine/ai"
 	"github.com/creack/gofour/runtime"
 	"github.com/creack/httpreq"
 	"github.com/creack/uuid"
@@ -20,57 +23,178 @@ func init() {
 // Runtime is a HTTP server for Connect Four.
 type Runtime struct {
 	sync.RWMutex
-	games map[string]*engine.Four
+	games    map[string]*engine.Four
+	bots     map[string]map[engine.State]ai.Bot // Computer players by game.
+	sessions map[string]*session                // Tokens, chat and result by game.
+	mux      *http.ServeMux                     // Endpoints, see Handler.
 }
 
 // CreateGameReq is the request to create a new game.
 type CreateGameReq struct {
-	Cols     int
-	Rows     int
-	NPlayers int
-	NWin     int
+	Cols      int    `json:"cols"`
+	Rows      int    `json:"rows"`
+	NPlayers  int    `json:"nplayers"`
+	NWin      int    `json:"nwin"`
+	AI        string `json:"ai"`
+	Variant   string `json:"variant"`
+	Layout    string `json:"layout"`
+	Blockers  int    `json:"blockers"`
+	Seed      int    `json:"seed"`
+	Teams     string `json:"teams"`
+	TeamLines string `json:"team_lines"`
+	Ranking   bool   `json:"ranking"`
+	Invite    string `json:"invite"` // Makes the game private, the code is required to join.
+}
+
+// maxGridSize is the maximum number of columns, rows and pieces to align of the games of the server.
+const maxGridSize = 64
+
+// newCreateGameReq returns a creation request with the default settings.
+func newCreateGameReq() *CreateGameReq {
+	return &CreateGameReq{
+		Cols:      engine.DefaultCols,
+		Rows:      engine.DefaultRows,
+		NPlayers:  engine.DefaultNPlayers,
+		NWin:      engine.DefaultNWin,
+		Variant:   runtime.Variant,
+		TeamLines: engine.TeamLinesMixed,
+	}
 }
 
 // CreateGame is the http endpoint handling the game creation.
 //
 // Method: GET
 // Query String:
-// - cols:     int, columns count of the grid.
-// - rows:     int, rows count of the grid.
+// - cols:     int, columns count of the grid, up to 64.
+// - rows:     int, rows count of the grid, up to 64.
 // - nplayers: int, number of players allowed in the game.
-// - nwin:     int, number of consecutive field to win.
+// - nwin:     int, number of consecutive field to win, up to 64.
+// - ai:       string, computer players, e.g. "2:hard,3:easy". Defaults to the -ai flag.
+// - variant:  string, rules of the game, e.g. "popout". Defaults to the -variant flag.
+// - layout:   string, grid layout, rows separated by '/', e.g. "...../..#../..#..". Overrides cols and rows.
+// - blockers: int, number of blockers randomly placed on the grid, when no layout is given.
+// - seed:     int, seed for the random blockers, 0 for a random one.
+// - teams:    string, 1 indexed players playing together, e.g. "1+3,2+4".
+// - team_lines: string, "mixed" (default) when a line of any colors of a team wins, "color" when it must be of a single color.
+// - ranking:  bool, play on once a player wins, until everyone is ranked.
+// - invite:   string, makes the game private, the code is then required to join.
 // Response:
 // - json formatted UUID of the new game.
 func (r *Runtime) CreateGame(w http.ResponseWriter, req *http.Request) error {
 	if err := req.ParseForm(); err != nil {
 		return ehttp.NewError(http.StatusBadRequest, err)
 	}
-	data := &CreateGameReq{
-		Cols:     engine.DefaultCols,
-		Rows:     engine.DefaultRows,
-		NPlayers: engine.DefaultNPlayers,
-		NWin:     engine.DefaultNWin,
-	}
+	data := newCreateGameReq()
 	if err := (httpreq.ParsingMap{
 		{Field: "cols", Fct: httpreq.ToInt, Dest: &data.Cols},
 		{Field: "rows", Fct: httpreq.ToInt, Dest: &data.Rows},
 		{Field: "nplayers", Fct: httpreq.ToInt, Dest: &data.NPlayers},
 		{Field: "nwin", Fct: httpreq.ToInt, Dest: &data.NWin},
+		{Field: "ai", Fct: httpreq.ToString, Dest: &data.AI},
+		{Field: "variant", Fct: httpreq.ToString, Dest: &data.Variant},
+		{Field: "layout", Fct: httpreq.ToString, Dest: &data.Layout},
+		{Field: "blockers", Fct: httpreq.ToInt, Dest: &data.Blockers},
+		{Field: "seed", Fct: httpreq.ToInt, Dest: &data.Seed},
+		{Field: "teams", Fct: httpreq.ToString, Dest: &data.Teams},
+		{Field: "team_lines", Fct: httpreq.ToString, Dest: &data.TeamLines},
+		{Field: "ranking", Fct: httpreq.ToBool, Dest: &data.Ranking},
+		{Field: "invite", Fct: httpreq.ToString, Dest: &data.Invite},
 	}.Parse(req.Form)); err != nil {
 		return ehttp.NewError(http.StatusBadRequest, err)
 	}
+	gameID, _, err := r.createGame(data)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(gameID)
+}
 
-	four, err := engine.NewConnectFour(data.Cols, data.Rows, data.NPlayers, data.NWin)
+// createGame validates the request and registers the new game.
+// Returns the UUID of the game along with the game itself.
+func (r *Runtime) createGame(data *CreateGameReq) (string, *engine.Four, error) {
+	if _, ok := engine.Variants[data.Variant]; !ok {
+		return "", nil, ehttp.NewErrorf(http.StatusBadRequest, "unknown variant '%s'", data.Variant)
+	}
+	layout := &engine.Layout{Columns: data.Cols, Rows: data.Rows}
+	if data.Layout != "" {
+		l, err := engine.ParseLayout(strings.NewReader(strings.Replace(data.Layout, "/", "\n", -1)))
+		if err != nil {
+			return "", nil, ehttp.NewError(http.StatusBadRequest, err)
+		}
+		layout = l
+	}
+	if layout.Columns > maxGridSize || layout.Rows > maxGridSize || data.NWin > maxGridSize {
+		return "", nil, ehttp.NewErrorf(http.StatusBadRequest, "grid too large: %dx%d with %d to win, maximum %d", layout.Columns, layout.Rows, data.NWin, maxGridSize)
+	}
+	if data.Layout == "" && data.Blockers != 0 {
+		seed := int64(data.Seed)
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		l, err := engine.RandomLayout(data.Cols, data.Rows, data.Blockers, seed)
+		if err != nil {
+			return "", nil, ehttp.NewError(http.StatusBadRequest, err)
+		}
+		layout = l
+	}
+	if data.TeamLines != engine.TeamLinesMixed && data.TeamLines != engine.TeamLinesColor {
+		return "", nil, ehttp.NewErrorf(http.StatusBadRequest, "invalid team_lines '%s', expected mixed or color", data.TeamLines)
+	}
+	teams, err := engine.ParseTeams(data.Teams, data.NPlayers)
+	if err != nil {
+		return "", nil, ehttp.NewError(http.StatusBadRequest, err)
+	}
+	opts := []engine.Option{engine.WithVariant(data.Variant), engine.WithBlockers(layout.Blockers...), engine.WithTeams(data.TeamLines == engine.TeamLinesMixed, teams...)}
+	if data.Ranking {
+		opts = append(opts, engine.WithRanking())
+	}
+	four, err := engine.NewConnectFour(layout.Columns, layout.Rows, data.NPlayers, data.NWin, opts...)
 	if err != nil {
-		return ehttp.NewErrorf(http.StatusInternalServerError, "error instantiating new game: %s", err)
+		return "", nil, ehttp.NewErrorf(http.StatusBadRequest, "invalid game: %s", err)
+	}
+
+	bots := map[engine.State]ai.Bot{}
+	if data.AI != "" {
+		if bots, err = ai.ParseSeats(data.AI, data.NPlayers); err != nil {
+			return "", nil, ehttp.NewError(http.StatusBadRequest, err)
+		}
+	} else {
+		for seat, bot := range runtime.Bots {
+			if int(seat) <= data.NPlayers {
+				bots[seat] = bot
+			}
+		}
+	}
+	// Computer players are seated right away.
+	for seat := range bots {
+		four.Players[seat] = fmt.Sprintf("computer %d", seat)
 	}
 
 	gameID := uuid.New()
 	r.Lock()
 	r.games[gameID] = four
+	r.bots[gameID] = bots
+	r.sessions[gameID] = newSession(data.Invite)
 	r.Unlock()
 
-	return json.NewEncoder(w).Encode(gameID)
+	return gameID, four, nil
+}
+
+// game returns the game with the given UUID.
+func (r *Runtime) game(gameID string) (*engine.Four, error) {
+	if gameID == "" {
+		return nil, ehttp.NewErrorf(http.StatusBadRequest, "missing game id")
+	}
+	if uuid.Parse(gameID) == nil {
+		return nil, ehttp.NewErrorf(http.StatusBadRequest, "invalid game id format")
+	}
+	r.RLock()
+	game := r.games[gameID]
+	r.RUnlock()
+	if game == nil {
+		return nil, ehttp.NewErrorf(http.StatusNotFound, "game '%s' not found", gameID)
+	}
+	return game, nil
 }
 
 // ListGameResp is the response
@@ -80,6 +204,10 @@ type ListGameResp struct {
 	MaxPlayerCount int                     `json:"max_player_count"`
 	GameState      string                  `json:"game_state"`
 	Players        map[engine.State]string `json:"players"`
+	Placements     []engine.State          `json:"placements,omitempty"` // Ranked players, first place first.
+	LiveWindows    map[engine.State]int    `json:"live_windows"`         // Lines each player can still complete.
+	Private        bool                    `json:"private,omitempty"`    // An invite code is required to join.
+	Result         *engine.Result          `json:"result,omitempty"`     // Set when a player resigned.
 }
 
 // ListGames is the http endpoint returning the list of games.
@@ -88,78 +216,156 @@ type ListGameResp struct {
 // Response:
 // - JSON array of ListGameResp.
 func (r *Runtime) ListGames(w http.ResponseWriter, req *http.Request) error {
+	return json.NewEncoder(w).Encode(r.listGames())
+}
+
+// listGames returns the summary of every game.
+func (r *Runtime) listGames() []ListGameResp {
+	// The games are summarized out of the server lock, taken while playing.
 	r.RLock()
-	ret := make([]ListGameResp, 0, len(r.games))
-	for gameID, game := range r.games {
-		gameState := "pending"
-		if game.GridState == engine.Stale {
-			gameState = "stale"
-		} else if game.GridState != engine.Empty {
-			gameState = fmt.Sprintf("won by %s %s", game.Players[game.GridState], game.GridState)
-		}
-		ret = append(ret, ListGameResp{
-			GameID:         gameID,
-			PlayerCount:    len(game.Players),
-			MaxPlayerCount: game.NPlayers,
-			GameState:      gameState,
-			Players:        game.Players,
-		})
+	ids := make([]string, 0, len(r.games))
+	for gameID := range r.games {
+		ids = append(ids, gameID)
+	}
+	games, sessions := make([]*engine.Four, len(ids)), make([]*session, len(ids))
+	for i, gameID := range ids {
+		games[i], sessions[i] = r.games[gameID], r.sessions[gameID]
 	}
 	r.RUnlock()
-	return json.NewEncoder(w).Encode(ret)
+
+	ret := make([]ListGameResp, 0, len(ids))
+	for i, gameID := range ids {
+		ret = append(ret, gameSummary(gameID, games[i], sessions[i]))
+	}
+	return ret
+}
+
+// gameSummary describes the given game.
+func gameSummary(gameID string, game *engine.Four, s *session) ListGameResp {
+	s.play.Lock()
+	defer s.play.Unlock()
+	s.RLock()
+	result, private := s.result, s.invite != ""
+	s.RUnlock()
+
+	gameState := "pending"
+	if result != nil {
+		gameState = fmt.Sprintf("resigned by %s %s", game.Players[result.Player], result.Player)
+	} else if game.Ranking && len(game.Placements) != 0 {
+		ranked := []string{}
+		for _, p := range game.Placements {
+			ranked = append(ranked, fmt.Sprintf("%s %s", game.Players[p], p))
+		}
+		if game.GridState == engine.Empty {
+			gameState = "pending, ranked " + strings.Join(ranked, ", ")
+		} else {
+			gameState = "ranked " + strings.Join(ranked, ", ")
+		}
+	} else if game.GridState == engine.Stale {
+		gameState = "stale"
+	} else if game.GridState != engine.Empty && len(game.Teams) != 0 {
+		winners := []string{}
+		for _, p := range game.Team(game.GridState) {
+			winners = append(winners, fmt.Sprintf("%s %s", game.Players[p], p))
+		}
+		gameState = "won by team " + strings.Join(winners, " + ")
+	} else if game.GridState != engine.Empty {
+		gameState = fmt.Sprintf("won by %s %s", game.Players[game.GridState], game.GridState)
+	}
+	live := map[engine.State]int{}
+	for _, p := range game.AvailablePlayers {
+		live[p] = game.LiveWindows(p)
+	}
+	players := map[engine.State]string{}
+	game.RLock()
+	for p, name := range game.Players {
+		players[p] = name
+	}
+	game.RUnlock()
+	return ListGameResp{
+		GameID:         gameID,
+		PlayerCount:    len(players),
+		MaxPlayerCount: game.NPlayers,
+		GameState:      gameState,
+		Players:        players,
+		Placements:     append([]engine.State(nil), game.Placements...),
+		LiveWindows:    live,
+		Private:        private,
+		Result:         result,
+	}
 }
 
 // AttachGame is the http endpoint to attach to a game.
 // This endpoint will send one message each time the game changes state until
-// the game is finished.
+// the game is finished. A reconnecting client only gets the current game, the
+// /v1/games/:id/stream Server-Sent Events stream resumes where it left off.
 //
 // Method: GET
 // Query String:
 // - game_id: string, game uuid to attach to.
 // Response:
-// - JSON object of engine.Four. One entry per state change.
+// - JSON object of engine.Four. One entry per state change. Once the game is won,
+// winning_lines holds the cells of the winning lines.
 func (r *Runtime) AttachGame(w http.ResponseWriter, req *http.Request) error {
 	if err := req.ParseForm(); err != nil {
 		return ehttp.NewError(http.StatusBadRequest, err)
 	}
 	gameID := req.Form.Get("game_id")
-	if gameID == "" {
-		return ehttp.NewErrorf(http.StatusBadRequest, "missing game_id")
-	}
-	if uuid.Parse(gameID) == nil {
-		return ehttp.NewErrorf(http.StatusBadRequest, "invalid game_id")
-	}
-	r.RLock()
-	game := r.games[gameID]
-	r.RUnlock()
-	if game == nil {
-		return ehttp.NewErrorf(http.StatusNotFound, "game '%s' not found", gameID)
+	game, err := r.game(gameID)
+	if err != nil {
+		return err
 	}
+	return r.attach(w, req, gameID, game)
+}
+
+// attach sends the game, then resends it on each state change reported by the
+// engine until the game is finished, resigned or the client leaves. A client too
+// slow to keep up is disconnected.
+func (r *Runtime) attach(w http.ResponseWriter, req *http.Request, gameID string, game *engine.Four) error {
+	// Subscribe along the current state so no change is missed after it.
+	s := r.session(gameID)
+	s.play.Lock()
+	activity := game.Subscribe()
+	current := game.Clone()
+	s.play.Unlock()
+	defer game.Unsubscribe(activity)
 
 	// Send current state.
 	encoder := json.NewEncoder(w)
-	if err := encoder.Encode(game); err != nil {
+	if err := encoder.Encode(current); err != nil {
 		return ehttp.NewError(http.StatusInternalServerError, err)
 	}
 	w.(http.Flusher).Flush()
 
 	// For each state change, resend the game.
-	if game.ActivityChan == nil {
-		return nil
-	}
-	for range game.ActivityChan {
-		if err := encoder.Encode(game); err != nil {
-			return ehttp.NewError(http.StatusInternalServerError, err)
+	for {
+		select {
+		case _, ok := <-activity:
+			if !ok {
+				return nil
+			}
+			if err := encoder.Encode(s.snapshot(game)); err != nil {
+				return ehttp.NewError(http.StatusInternalServerError, err)
+			}
+			w.(http.Flusher).Flush()
+		case <-req.Context().Done():
+			return nil
 		}
-		w.(http.Flusher).Flush()
 	}
-	return nil
 }
 
 // JoinGameReq is the request to join a game.
 type JoinGameReq struct {
-	GameID     string
-	PlayerName string
+	GameID     string `json:"-"`
+	PlayerName string `json:"player_name"`
+	Invite     string `json:"invite"` // Required for private games.
+}
+
+// JoinGameResp is the response to a player joining a game.
+type JoinGameResp struct {
+	Player     engine.State `json:"player"` // Seat taken by the player.
+	PlayerName string       `json:"player_name"`
+	Token      string       `json:"token"` // Secret bound to the seat, required to act as the player.
 }
 
 // JoinGame is the http endpoint to join a game.
@@ -168,6 +374,10 @@ type JoinGameReq struct {
 // Query String:
 // - game_id:     string, uuid of the game to join.
 // - player_name: string, arbitrary player name.
+// - invite:      string, invite code, required for private games.
+// Response:
+// - JSON object of JoinGameResp. The token is to be sent in the X-Player-Token
+// header, or as a bearer Authorization header, to play as the player.
 func (r *Runtime) JoinGame(w http.ResponseWriter, req *http.Request) error {
 	if err := req.ParseForm(); err != nil {
 		return ehttp.NewError(http.StatusBadRequest, err)
@@ -176,125 +386,314 @@ func (r *Runtime) JoinGame(w http.ResponseWriter, req *http.Request) error {
 	if err := (httpreq.ParsingMap{
 		{Field: "game_id", Fct: httpreq.ToString, Dest: &data.GameID},
 		{Field: "player_name", Fct: httpreq.ToString, Dest: &data.PlayerName},
+		{Field: "invite", Fct: httpreq.ToString, Dest: &data.Invite},
 	}.Parse(req.Form)); err != nil {
 		return ehttp.NewError(http.StatusBadRequest, err)
 	}
-	if data.PlayerName == "" {
-		return ehttp.NewErrorf(http.StatusBadRequest, "missing player name")
+	resp, err := r.joinGame(data)
+	if err != nil {
+		return err
 	}
-	if data.GameID == "" {
-		return ehttp.NewErrorf(http.StatusBadRequest, "missing game id")
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// joinGame seats the player in the first free seat of the game and binds a new token to it.
+func (r *Runtime) joinGame(data *JoinGameReq) (*JoinGameResp, error) {
+	if data.PlayerName == "" {
+		return nil, ehttp.NewErrorf(http.StatusBadRequest, "missing player name")
 	}
-	if uuid.Parse(data.GameID) == nil {
-		return ehttp.NewErrorf(http.StatusBadRequest, "invalid game id format")
+	game, err := r.game(data.GameID)
+	if err != nil {
+		return nil, err
 	}
-	r.RLock()
-	game := r.games[data.GameID]
-	r.RUnlock()
-	if game == nil {
-		return ehttp.NewErrorf(http.StatusNotFound, "game '%s' not found", data.GameID)
+	s := r.session(data.GameID)
+	if s.invite != "" && data.Invite != s.invite {
+		return nil, ehttp.NewErrorf(http.StatusForbidden, "invalid invite code for game '%s'", data.GameID)
 	}
-	if len(game.Players) >= game.NPlayers {
-		return ehttp.NewErrorf(http.StatusForbidden, "game '%s' is full", data.GameID)
+	token, err := newToken()
+	if err != nil {
+		return nil, ehttp.NewErrorf(http.StatusInternalServerError, "error generating player token: %s", err)
 	}
+
+	s.play.Lock()
+	seat := engine.State(engine.Empty)
+	game.Lock()
 	for _, playerName := range game.Players {
 		if playerName == data.PlayerName {
-			return ehttp.NewErrorf(http.StatusForbidden, "user '%s' already joined game '%s'", data.PlayerName, data.GameID)
+			game.Unlock()
+			s.play.Unlock()
+			return nil, ehttp.NewErrorf(http.StatusForbidden, "user '%s' already joined game '%s'", data.PlayerName, data.GameID)
 		}
 	}
-	game.Lock()
-	game.Players[engine.State(len(game.Players)+1)] = data.PlayerName
+	for _, p := range game.AvailablePlayers {
+		if _, ok := game.Players[p]; !ok {
+			game.Players[p] = data.PlayerName
+			seat = p
+			break
+		}
+	}
+	full := len(game.Players) == game.NPlayers
 	game.Unlock()
+	if seat == engine.Empty {
+		s.play.Unlock()
+		return nil, ehttp.NewErrorf(http.StatusForbidden, "game '%s' is full", data.GameID)
+	}
+	s.Lock()
+	s.tokens[token] = seat
+	s.Unlock()
+	s.publish(Event{Type: EventPlayerJoined, Player: seat, PlayerName: data.PlayerName})
+	s.play.Unlock()
 
-	return nil
+	// Once everyone is there, let the computer players start if needed.
+	if full {
+		if err := r.playBots(data.GameID, game); err != nil {
+			return nil, ehttp.NewErrorf(http.StatusInternalServerError, "an error occured while playing a computer move: %s", err)
+		}
+	}
+	return &JoinGameResp{Player: seat, PlayerName: data.PlayerName, Token: token}, nil
 }
 
 // PlayMoveReq is the request to play a move in a game.
 type PlayMoveReq struct {
-	GameID     string
-	PlayerName string
-	Column     int
+	GameID     string `json:"-"`
+	Token      string `json:"-"`
+	PlayerName string `json:"player_name"` // Optional, must be the one of the token's seat.
+	Column     int    `json:"col"`
+	Row        int    `json:"row"`
+	Action     string `json:"action"`
+}
+
+// newPlayMoveReq returns a move request with the default settings.
+func newPlayMoveReq() *PlayMoveReq {
+	return &PlayMoveReq{
+		Column: -1,
+		Row:    -1,
+		Action: "drop",
+	}
 }
 
 // PlayMove is the http endpoint to submit a move.
 //
 // Method: GET
+// Header:
+// - X-Player-Token: string, token of the player, from JoinGame. Also accepted as bearer Authorization.
 // Query String:
 // - game_id:     string, uuid of the target game.
-// - player_name: string, name of the player, must have joined the game.
+// - player_name: string, optional name of the player, must be the one of the token.
 // - col:         int,    0 indexed column number to play.
+// - row:         int,    0 indexed row number, from the top, for free placement variants.
+// - action:      string, "drop" (default) or "pop" to remove the bottom piece, if the variant allows it.
 func (r *Runtime) PlayMove(w http.ResponseWriter, req *http.Request) error {
 	if err := req.ParseForm(); err != nil {
 		return ehttp.NewError(http.StatusBadRequest, err)
 	}
 
-	data := PlayMoveReq{
-		Column: -1,
-	}
+	data := newPlayMoveReq()
+	data.Token = requestToken(req)
 	if err := (httpreq.ParsingMap{
 		{Field: "game_id", Fct: httpreq.ToString, Dest: &data.GameID},
 		{Field: "player_name", Fct: httpreq.ToString, Dest: &data.PlayerName},
 		{Field: "col", Fct: httpreq.ToInt, Dest: &data.Column},
+		{Field: "row", Fct: httpreq.ToInt, Dest: &data.Row},
+		{Field: "action", Fct: httpreq.ToString, Dest: &data.Action},
 	}.Parse(req.Form)); err != nil {
 		return ehttp.NewError(http.StatusBadRequest, err)
 	}
+	_, err := r.playMove(data)
+	return err
+}
+
+// playMove plays the requested move, then lets the computer players move.
+// Returns the game.
+func (r *Runtime) playMove(data *PlayMoveReq) (*engine.Four, error) {
 	if data.Column == -1 {
-		return ehttp.NewErrorf(http.StatusBadRequest, "missing column to be played")
+		return nil, ehttp.NewErrorf(http.StatusBadRequest, "missing column to be played")
 	}
-	if data.PlayerName == "" {
-		return ehttp.NewErrorf(http.StatusBadRequest, "missing player name")
+	if data.Action != "drop" && data.Action != "pop" {
+		return nil, ehttp.NewErrorf(http.StatusBadRequest, "invalid action '%s', expected drop or pop", data.Action)
 	}
-	if data.GameID == "" {
-		return ehttp.NewErrorf(http.StatusBadRequest, "missing game id")
+	game, err := r.game(data.GameID)
+	if err != nil {
+		return nil, err
 	}
-	if uuid.Parse(data.GameID) == nil {
-		return ehttp.NewErrorf(http.StatusBadRequest, "invalid game id format")
+	player, err := r.seat(data.GameID, game, data.Token, data.PlayerName)
+	if err != nil {
+		return nil, err
 	}
-	r.RLock()
-	game := r.games[data.GameID]
-	r.RUnlock()
-	if game == nil {
-		return ehttp.NewErrorf(http.StatusNotFound, "game '%s' not found", data.GameID)
+	s := r.session(data.GameID)
+	if err := r.playHuman(data, game, s, player); err != nil {
+		return nil, err
+	}
+	if err := r.playBots(data.GameID, game); err != nil {
+		return nil, ehttp.NewErrorf(http.StatusInternalServerError, "an error occured while playing a computer move: %s", err)
 	}
+	return s.snapshot(game), nil
+}
+
+// playHuman validates and plays the move of the player, under the play lock of the game.
+func (r *Runtime) playHuman(data *PlayMoveReq, game *engine.Four, s *session, player engine.State) error {
+	s.play.Lock()
+	defer s.play.Unlock()
 	if len(game.Players) != game.NPlayers {
 		return ehttp.NewErrorf(http.StatusForbidden, "game '%s' is not ready, waiting on players", data.GameID)
 	}
-	var player engine.State
-	for s, playerName := range game.Players {
-		if playerName == data.PlayerName {
-			player = s
-			break
-		}
+	if err := r.finished(data.GameID, game); err != nil {
+		return err
 	}
-	if player == engine.Empty {
-		return ehttp.NewErrorf(http.StatusForbidden, "player not found in game '%s'", data.GameID)
+	if game.FreePlacement() && data.Row == -1 {
+		return ehttp.NewErrorf(http.StatusBadRequest, "missing row to be played")
 	}
-	if err := game.ValidateMove(player, data.Column); err != nil {
-		return ehttp.NewErrorf(http.StatusForbidden, "invalid move for player '%s' in game '%s': %s", data.PlayerName, data.GameID, err)
+	m := engine.Move{Player: player, Column: data.Column, Row: data.Row, Pop: data.Action == "pop"}
+	if err := game.Validate(m); err != nil {
+		return ehttp.NewErrorf(http.StatusForbidden, "invalid move for player '%s' in game '%s': %s", game.Players[player], data.GameID, err)
 	}
-	if _, err := game.PlayerMove(player, data.Column); err != nil {
+	if _, _, err := game.Play(m); err != nil {
 		return ehttp.NewErrorf(http.StatusInternalServerError, "an error occured while playing a move: %s", err)
 	}
+	r.publishMove(data.GameID, game)
 	return nil
 }
 
+// playBots lets the computer players of the game move until it is a human's turn or the game is over.
+// The bots search a copy of the game, out of the play lock, and their move is
+// dropped if the game changed meanwhile, e.g. taken back or resigned.
+func (r *Runtime) playBots(gameID string, game *engine.Four) error {
+	r.RLock()
+	bots := r.bots[gameID]
+	r.RUnlock()
+	s := r.session(gameID)
+	for {
+		s.play.Lock()
+		bot, ok := bots[game.CurPlayer]
+		if !ok || r.finished(gameID, game) != nil {
+			s.play.Unlock()
+			return nil
+		}
+		pos, n, hash := game.Clone(), len(game.History), game.Hash()
+		s.play.Unlock()
+
+		m, err := bot.Move(pos)
+		if err != nil {
+			return err
+		}
+
+		s.play.Lock()
+		if len(game.History) != n || game.Hash() != hash || r.finished(gameID, game) != nil {
+			s.play.Unlock()
+			continue
+		}
+		if _, _, err := game.Play(m); err != nil {
+			s.play.Unlock()
+			return err
+		}
+		r.publishMove(gameID, game)
+		s.play.Unlock()
+	}
+}
+
+// TakeBackReq is the request to take back a move.
+type TakeBackReq struct {
+	GameID     string `json:"-"`
+	Token      string `json:"-"`
+	PlayerName string `json:"player_name"` // Optional, must be the one of the token's seat.
+}
+
+// TakeBack is the http endpoint to take back the last move.
+// Only the player who played the last move can take it back, and only
+// while the game is still running. The computer moves played after it
+// are taken back as well.
+//
+// Method: GET
+// Header:
+// - X-Player-Token: string, token of the player, from JoinGame. Also accepted as bearer Authorization.
+// Query String:
+// - game_id:     string, uuid of the target game.
+// - player_name: string, optional name of the player, must be the one of the token.
+func (r *Runtime) TakeBack(w http.ResponseWriter, req *http.Request) error {
+	if err := req.ParseForm(); err != nil {
+		return ehttp.NewError(http.StatusBadRequest, err)
+	}
+	data := &TakeBackReq{Token: requestToken(req)}
+	if err := (httpreq.ParsingMap{
+		{Field: "game_id", Fct: httpreq.ToString, Dest: &data.GameID},
+		{Field: "player_name", Fct: httpreq.ToString, Dest: &data.PlayerName},
+	}.Parse(req.Form)); err != nil {
+		return ehttp.NewError(http.StatusBadRequest, err)
+	}
+	_, err := r.takeBack(data)
+	return err
+}
+
+// takeBack takes back the last move of the requesting player along with
+// the computer moves played after it. Returns the moves taken back, most recent first.
+func (r *Runtime) takeBack(data *TakeBackReq) ([]engine.Move, error) {
+	game, err := r.game(data.GameID)
+	if err != nil {
+		return nil, err
+	}
+	player, err := r.seat(data.GameID, game, data.Token, data.PlayerName)
+	if err != nil {
+		return nil, err
+	}
+	r.RLock()
+	bots := r.bots[data.GameID]
+	r.RUnlock()
+	s := r.session(data.GameID)
+	s.play.Lock()
+	defer s.play.Unlock()
+	if err := r.finished(data.GameID, game); err != nil {
+		return nil, err
+	}
+	last := engine.State(engine.Empty)
+	for i := len(game.History) - 1; i >= 0; i-- {
+		if _, ok := bots[game.History[i].Player]; !ok {
+			last = game.History[i].Player
+			break
+		}
+	}
+	if last == engine.Empty {
+		return nil, ehttp.NewErrorf(http.StatusForbidden, "no move to take back in game '%s'", data.GameID)
+	}
+	if last != player {
+		return nil, ehttp.NewErrorf(http.StatusForbidden, "last move in game '%s' was not played by '%s'", data.GameID, game.Players[player])
+	}
+	moves, err := runtime.TakeBack(game, bots)
+	if err != nil {
+		return nil, ehttp.NewErrorf(http.StatusInternalServerError, "an error occured while taking back a move: %s", err)
+	}
+	s.publish(Event{Type: EventTakeBack, Moves: moves, NextPlayer: game.CurPlayer})
+	return moves, nil
+}
+
 // Init setup the connect four game.
 // Note: In server mode, we discard the init's given engine.
+// The legacy GET endpoints are kept along the v1 API, see newRouter.
 func (r *Runtime) Init(four *engine.Four) error {
 	r.games = map[string]*engine.Four{}
+	r.bots = map[string]map[engine.State]ai.Bot{}
+	r.sessions = map[string]*session{}
 
-	ehttp.HandleFunc("/create", ehttp.HandlerFunc(r.CreateGame))
-	ehttp.HandleFunc("/join", ehttp.HandlerFunc(r.JoinGame))
-	ehttp.HandleFunc("/list", ehttp.HandlerFunc(r.ListGames))
-	ehttp.HandleFunc("/attach", ehttp.HandlerFunc(r.AttachGame))
-	ehttp.HandleFunc("/play", ehttp.HandlerFunc(r.PlayMove))
+	r.mux = http.NewServeMux()
+	r.mux.Handle("/v1/", r.newRouter())
+	r.mux.Handle("/create", ehttp.HandlerFunc(r.CreateGame))
+	r.mux.Handle("/join", ehttp.HandlerFunc(r.JoinGame))
+	r.mux.Handle("/list", ehttp.HandlerFunc(r.ListGames))
+	r.mux.Handle("/attach", ehttp.HandlerFunc(r.AttachGame))
+	r.mux.Handle("/play", ehttp.HandlerFunc(r.PlayMove))
+	r.mux.Handle("/takeback", ehttp.HandlerFunc(r.TakeBack))
 	return nil
 }
 
+// Handler returns the endpoints of the server, e.g. to serve them with
+// net/http/httptest. Init must be called first.
+func (r *Runtime) Handler() http.Handler {
+	return r.mux
+}
+
 // Run is the main loop.
 // TODO: Use flags to config the listen address.
 func (r *Runtime) Run() error {
-	return http.ListenAndServe("0.0.0.0:8080", nil)
+	return http.ListenAndServe("0.0.0.0:8080", r.mux)
 }
 
 // Close .
@@ -0,0 +1,437 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/creack/ehttp"
+	"github.com/creack/gofour/engine"
+)
+
+// TestAttach checks every attached client receives every change of the game,
+// until it ends.
+func TestAttach(t *testing.T) {
+	r := &Runtime{}
+	if err := r.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(r.Handler())
+	defer srv.Close()
+
+	gameID, _, err := r.createGame(newCreateGameReq())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tokens []string
+	for _, name := range []string{"alice", "bob"} {
+		resp, err := r.joinGame(&JoinGameReq{GameID: gameID, PlayerName: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, resp.Token)
+	}
+
+	var clients []*json.Decoder
+	for _, u := range []string{"/v1/games/" + gameID + "/events", "/attach?game_id=" + gameID} {
+		resp, err := http.Get(srv.URL + u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		clients = append(clients, json.NewDecoder(resp.Body))
+	}
+	expect := func(moves int) {
+		for i, dec := range clients {
+			var game engine.Four
+			if err := dec.Decode(&game); err != nil {
+				t.Fatalf("Unexpected error reading client %d: %s", i, err)
+			}
+			if len(game.History) != moves {
+				t.Fatalf("Unexpected moves sent to client %d.\nExpected:\t%d\nGot:\t\t%d", i, moves, len(game.History))
+			}
+		}
+	}
+	expect(0)
+
+	if _, err := r.playMove(&PlayMoveReq{GameID: gameID, Token: tokens[0], Column: 3, Row: -1, Action: "drop"}); err != nil {
+		t.Fatal(err)
+	}
+	expect(1)
+	if _, err := r.takeBack(&TakeBackReq{GameID: gameID, Token: tokens[0]}); err != nil {
+		t.Fatal(err)
+	}
+	expect(0)
+	if _, err := r.playMove(&PlayMoveReq{GameID: gameID, Token: tokens[0], Column: 3, Row: -1, Action: "drop"}); err != nil {
+		t.Fatal(err)
+	}
+	expect(1)
+
+	// The chat leaves the game as is, the resign releases the game and ends the stream.
+	if _, err := r.chat(&ChatReq{GameID: gameID, Token: tokens[1], Message: "gg"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.resign(&ResignReq{GameID: gameID, Token: tokens[1]}); err != nil {
+		t.Fatal(err)
+	}
+	for i, dec := range clients {
+		var game engine.Four
+		if err := dec.Decode(&game); err != io.EOF {
+			t.Fatalf("Unexpected end of client %d.\nExpected:\t%v\nGot:\t\t%v", i, io.EOF, err)
+		}
+	}
+
+	// Attaching to a finished game sends it once.
+	resp, err := http.Get(srv.URL + "/v1/games/" + gameID + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	clients = []*json.Decoder{json.NewDecoder(resp.Body)}
+	expect(1)
+	var game engine.Four
+	if err := clients[0].Decode(&game); err != io.EOF {
+		t.Fatalf("Unexpected end after a finished game.\nExpected:\t%v\nGot:\t\t%v", io.EOF, err)
+	}
+}
+
+// TestCreateGameErrors checks the invalid games are rejected as bad requests.
+func TestCreateGameErrors(t *testing.T) {
+	r := &Runtime{}
+	if err := r.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, elem := range []struct {
+		name string
+		edit func(*CreateGameReq)
+	}{
+		{name: "too many columns", edit: func(data *CreateGameReq) { data.Cols = maxGridSize + 1 }},
+		{name: "too many rows", edit: func(data *CreateGameReq) { data.Rows = 1 << 20 }},
+		{name: "too long lines", edit: func(data *CreateGameReq) { data.Cols, data.NWin = maxGridSize, maxGridSize+1 }},
+		{name: "too large layout", edit: func(data *CreateGameReq) {
+			data.Layout = strings.Repeat(".", maxGridSize+1) + "/" + strings.Repeat(".", maxGridSize+1)
+		}},
+		{name: "no player", edit: func(data *CreateGameReq) { data.NPlayers = 0 }},
+		{name: "too many players", edit: func(data *CreateGameReq) { data.NPlayers = len(engine.AvailablePlayers) + 1 }},
+		{name: "grid too small", edit: func(data *CreateGameReq) { data.Cols, data.Rows = 3, 3 }},
+		{name: "narrow cylinder", edit: func(data *CreateGameReq) { data.Variant, data.Cols = "cylinder", 3 }},
+		{name: "blocked grid", edit: func(data *CreateGameReq) { data.Layout = "##/##" }},
+		{name: "uneven teams", edit: func(data *CreateGameReq) { data.NPlayers, data.Teams = 3, "1,2" }},
+		{name: "ranked teams", edit: func(data *CreateGameReq) { data.NPlayers, data.Teams, data.Ranking = 4, "1+3,2+4", true }},
+	} {
+		data := newCreateGameReq()
+		elem.edit(data)
+		_, _, err := r.createGame(data)
+		if e, ok := err.(*ehttp.Error); !ok || e.Code() != http.StatusBadRequest {
+			t.Errorf("[%s] Unexpected error.\nExpected:\tstatus %d\nGot:\t\t%v", elem.name, http.StatusBadRequest, err)
+		}
+	}
+
+	// Both APIs.
+	srv := httptest.NewServer(r.Handler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/create?nplayers=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Unexpected status of the legacy API.\nExpected:\t%d\nGot:\t\t%d", http.StatusBadRequest, resp.StatusCode)
+	}
+	resp, err = http.Post(srv.URL+"/v1/games", "application/json", strings.NewReader(`{"nplayers": 0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Unexpected status of the v1 API.\nExpected:\t%d\nGot:\t\t%d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	if len(r.listGames()) != 0 {
+		t.Fatalf("Unexpected games after failed creations: %d", len(r.listGames()))
+	}
+}
+
+// TestCreateGameBlockers checks the random blockers follow the given seed, or
+// change from one game to the next without seed.
+func TestCreateGameBlockers(t *testing.T) {
+	r := &Runtime{}
+	if err := r.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	blockers := func(seed int) []engine.Cell {
+		data := newCreateGameReq()
+		data.Blockers, data.Seed = 10, seed
+		_, game, err := r.createGame(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return game.Blockers
+	}
+	if a, b := blockers(7), blockers(7); !reflect.DeepEqual(a, b) {
+		t.Fatalf("Unexpected blockers with the same seed.\nExpected:\t%v\nGot:\t\t%v", a, b)
+	}
+	if a, b := blockers(0), blockers(0); reflect.DeepEqual(a, b) {
+		t.Fatalf("Unexpected same blockers without seed: %v", a)
+	}
+}
+
+// TestConcurrentPlay plays, takes back and reads a game against a computer
+// from several clients at once. The events published must replay to the game.
+func TestConcurrentPlay(t *testing.T) {
+	r := &Runtime{}
+	if err := r.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	data := newCreateGameReq()
+	data.Cols, data.Rows, data.NWin, data.AI = 9, 8, 5, "2:easy"
+	gameID, game, err := r.createGame(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	join, err := r.joinGame(&JoinGameReq{GameID: gameID, PlayerName: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := r.session(gameID)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < 20; n++ {
+				if n%5 == 4 {
+					_, _ = r.takeBack(&TakeBackReq{GameID: gameID, Token: join.Token})
+					continue
+				}
+				_, _ = r.playMove(&PlayMoveReq{GameID: gameID, Token: join.Token, Column: (i + n) % data.Cols, Row: -1, Action: "drop"})
+			}
+		}(i)
+	}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 20; n++ {
+				if _, err := json.Marshal(r.listGames()); err != nil {
+					t.Error(err)
+				}
+				if _, err := json.Marshal(s.snapshot(game)); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Replay the published events on a new game.
+	f, err := engine.NewConnectFour(data.Cols, data.Rows, data.NPlayers, data.NWin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RLock()
+	events := append([]Event(nil), s.log...)
+	s.RUnlock()
+	for i, e := range events {
+		if e.ID != uint64(i+1) {
+			t.Fatalf("Unexpected event ID.\nExpected:\t%d\nGot:\t\t%d", i+1, e.ID)
+		}
+		switch e.Type {
+		case EventMovePlayed:
+			if _, _, err := f.Play(*e.Move); err != nil {
+				t.Fatalf("Unexpected error replaying event %d: %s", e.ID, err)
+			}
+		case EventTakeBack:
+			for range e.Moves {
+				if _, err := f.Undo(); err != nil {
+					t.Fatalf("Unexpected error replaying event %d: %s", e.ID, err)
+				}
+			}
+		}
+	}
+	if f.Hash() != game.Hash() || len(f.History) != len(game.History) || f.GridState != game.GridState {
+		t.Fatalf("Unexpected game replayed from the events.\nExpected:\t%s\nGot:\t\t%s", game.Notation(), f.Notation())
+	}
+	for i, m := range game.History {
+		if expect := engine.AvailablePlayers[i%2]; m.Player != expect {
+			t.Fatalf("Unexpected player of move %d.\nExpected:\t%s\nGot:\t\t%s", i+1, expect, m.Player)
+		}
+	}
+}
+
+// TestGameOver checks the end of the game is logged along the move ending it,
+// before any snapshot may show the game finished, and the resigned games are released.
+func TestGameOver(t *testing.T) {
+	r := &Runtime{}
+	if err := r.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	play := func() (string, *engine.Four, []string) {
+		gameID, game, err := r.createGame(newCreateGameReq())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tokens []string
+		for _, name := range []string{"alice", "bob"} {
+			resp, err := r.joinGame(&JoinGameReq{GameID: gameID, PlayerName: name})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokens = append(tokens, resp.Token)
+		}
+		return gameID, game, tokens
+	}
+
+	gameID, game, tokens := play()
+	events, _ := r.session(gameID).subscribe()
+	for i, col := range []int{0, 1, 0, 1, 0, 1, 0} {
+		if _, err := r.playMove(&PlayMoveReq{GameID: gameID, Token: tokens[i%2], Column: col, Row: -1, Action: "drop"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 7; i++ {
+		if e := <-events; e.Type != EventMovePlayed {
+			t.Fatalf("Unexpected event %d.\nExpected:\t%s\nGot:\t\t%s", e.ID, EventMovePlayed, e.Type)
+		}
+	}
+	select {
+	case e := <-events:
+		if e.Type != EventGameOver || e.GridState != engine.Red || !reflect.DeepEqual(e.WinningLines, game.WinningLines) {
+			t.Fatalf("Unexpected end of the game: %+v", e)
+		}
+	default:
+		t.Fatal("Unexpected finished game without its end published")
+	}
+
+	gameID, game, tokens = play()
+	if _, err := r.resign(&ResignReq{GameID: gameID, Token: tokens[1]}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-game.Subscribe(); ok {
+		t.Fatal("Unexpected subscription to a resigned game")
+	}
+}
+
+// TestAuth checks the invite code of private games is required to join, and
+// the seat token to move, on both APIs.
+func TestAuth(t *testing.T) {
+	r := &Runtime{}
+	if err := r.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(r.Handler())
+	defer srv.Close()
+
+	// request sends the request and returns its status, decoding the body in dest, if any.
+	request := func(method, u, body string, header http.Header, dest interface{}) int {
+		req, err := http.NewRequest(method, srv.URL+u, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if dest != nil && resp.StatusCode < 300 {
+			if err := json.NewDecoder(resp.Body).Decode(dest); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	data := newCreateGameReq()
+	data.Invite = "secret"
+	gameID, game, err := r.createGame(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Joins, alice on the legacy API, bob on the v1 one.
+	var alice, bob JoinGameResp
+	for _, tc := range []struct {
+		name   string
+		method string
+		u      string
+		body   string
+		dest   *JoinGameResp
+		expect int
+	}{
+		{name: "legacy without invite", method: "GET", u: "/join?game_id=" + gameID + "&player_name=alice", expect: http.StatusForbidden},
+		{name: "legacy wrong invite", method: "GET", u: "/join?game_id=" + gameID + "&player_name=alice&invite=guess", expect: http.StatusForbidden},
+		{name: "legacy", method: "GET", u: "/join?game_id=" + gameID + "&player_name=alice&invite=secret", dest: &alice, expect: http.StatusOK},
+		{name: "v1 without invite", method: "POST", u: "/v1/games/" + gameID + "/players", body: `{"player_name": "bob"}`, expect: http.StatusForbidden},
+		{name: "v1 wrong invite", method: "POST", u: "/v1/games/" + gameID + "/players", body: `{"player_name": "bob", "invite": "guess"}`, expect: http.StatusForbidden},
+		{name: "v1", method: "POST", u: "/v1/games/" + gameID + "/players", body: `{"player_name": "bob", "invite": "secret"}`, dest: &bob, expect: http.StatusCreated},
+	} {
+		var dest interface{}
+		if tc.dest != nil {
+			dest = tc.dest
+		}
+		if status := request(tc.method, tc.u, tc.body, nil, dest); status != tc.expect {
+			t.Fatalf("[%s] Unexpected join status.\nExpected:\t%d\nGot:\t\t%d", tc.name, tc.expect, status)
+		}
+	}
+	if alice.Player != engine.Red || alice.Token == "" || bob.Player != engine.Yellow || bob.Token == "" || alice.Token == bob.Token {
+		t.Fatalf("Unexpected seats: %+v %+v", alice, bob)
+	}
+
+	// Moves, each API playing the turns of both players.
+	moves := 0
+	for _, api := range []struct {
+		name string
+		move func(header http.Header, playerName string, col int) int
+		ok   int
+	}{
+		{
+			name: "legacy",
+			move: func(header http.Header, playerName string, col int) int {
+				return request("GET", fmt.Sprintf("/play?game_id=%s&player_name=%s&col=%d", gameID, playerName, col), "", header, nil)
+			},
+			ok: http.StatusOK,
+		},
+		{
+			name: "v1",
+			move: func(header http.Header, playerName string, col int) int {
+				return request("POST", "/v1/games/"+gameID+"/moves", fmt.Sprintf(`{"player_name": %q, "col": %d}`, playerName, col), header, nil)
+			},
+			ok: http.StatusCreated,
+		},
+	} {
+		for _, tc := range []struct {
+			name       string
+			header     http.Header
+			playerName string
+			expect     int
+		}{
+			{name: "without token", expect: http.StatusUnauthorized},
+			{name: "unknown token", header: http.Header{TokenHeader: {"invalid"}}, expect: http.StatusUnauthorized},
+			{name: "unknown bearer", header: http.Header{"Authorization": {"Bearer invalid"}}, expect: http.StatusUnauthorized},
+			{name: "other seat", header: http.Header{TokenHeader: {alice.Token}}, playerName: "bob", expect: http.StatusForbidden},
+			{name: "other seat bearer", header: http.Header{"Authorization": {"Bearer " + bob.Token}}, playerName: "alice", expect: http.StatusForbidden},
+			{name: "alice", header: http.Header{TokenHeader: {alice.Token}}, playerName: "alice", expect: api.ok},
+			{name: "bob bearer", header: http.Header{"Authorization": {"Bearer " + bob.Token}}, expect: api.ok},
+		} {
+			if status := api.move(tc.header, tc.playerName, 3); status != tc.expect {
+				t.Fatalf("[%s %s] Unexpected move status.\nExpected:\t%d\nGot:\t\t%d", api.name, tc.name, tc.expect, status)
+			}
+			if tc.expect == api.ok {
+				moves++
+			}
+			if len(game.History) != moves {
+				t.Fatalf("[%s %s] Unexpected moves played.\nExpected:\t%d\nGot:\t\t%d", api.name, tc.name, moves, len(game.History))
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creack/ehttp"
+	"github.com/creack/gofour/engine"
+)
+
+// TokenHeader is the header holding the seat token of a player. The token
+// may also be sent as a bearer Authorization header.
+const TokenHeader = "X-Player-Token"
+
+// session is the server side state of a game, next to the engine.
+// The play lock serializes the changes of the game: it is held from the
+// validation of a change to the publication of its events, and to take the
+// snapshots of the game, which then match the events published so far.
+type session struct {
+	sync.RWMutex
+	play   sync.Mutex
+	tokens map[string]engine.State // Seats by secret token, see JoinGame.
+	invite string                  // Code required to join, empty for public games.
+	chat   []ChatMessage           // Messages of the players, oldest first.
+	result *engine.Result          // Set when a player resigns.
+	subs   map[chan Event]bool     // Listeners of the game events, see subscribe.
+	log    []Event                 // Latest events, oldest first, see resume.
+	lastID uint64                  // ID of the last event published.
+}
+
+// newSession returns the session of a new game, private when the invite code is set.
+func newSession(invite string) *session {
+	return &session{
+		tokens: map[string]engine.State{},
+		invite: invite,
+		subs:   map[chan Event]bool{},
+	}
+}
+
+// snapshot returns a copy of the game, consistent with the events published so far.
+func (s *session) snapshot(game *engine.Four) *engine.Four {
+	s.play.Lock()
+	defer s.play.Unlock()
+	return game.Clone()
+}
+
+// ChatMessage is a message sent by a player to the game.
+type ChatMessage struct {
+	Player     engine.State `json:"player"`
+	PlayerName string       `json:"player_name"`
+	Message    string       `json:"message"`
+	Time       time.Time    `json:"time"`
+}
+
+// newToken returns a random secret token.
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// requestToken returns the seat token of the request, from the TokenHeader
+// header or a bearer Authorization header.
+func requestToken(req *http.Request) string {
+	if token := req.Header.Get(TokenHeader); token != "" {
+		return token
+	}
+	const prefix = "Bearer "
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, prefix) {
+		return strings.TrimSpace(auth[len(prefix):])
+	}
+	return ""
+}
+
+// session returns the session of the given game.
+func (r *Runtime) session(gameID string) *session {
+	r.RLock()
+	defer r.RUnlock()
+	return r.sessions[gameID]
+}
+
+// seat returns the seat bound to the token in the given game.
+// When a player name is given, it must be the one of the seat.
+func (r *Runtime) seat(gameID string, game *engine.Four, token, playerName string) (engine.State, error) {
+	if token == "" {
+		return engine.Empty, ehttp.NewErrorf(http.StatusUnauthorized, "missing player token")
+	}
+	s := r.session(gameID)
+	s.RLock()
+	seat, ok := s.tokens[token]
+	s.RUnlock()
+	if !ok {
+		return engine.Empty, ehttp.NewErrorf(http.StatusUnauthorized, "invalid player token for game '%s'", gameID)
+	}
+	game.RLock()
+	name := game.Players[seat]
+	game.RUnlock()
+	if playerName != "" && playerName != name {
+		return engine.Empty, ehttp.NewErrorf(http.StatusForbidden, "player token of game '%s' does not belong to '%s'", gameID, playerName)
+	}
+	return seat, nil
+}
+
+// finished returns an error if the game is over, won, drawn or resigned.
+func (r *Runtime) finished(gameID string, game *engine.Four) error {
+	s := r.session(gameID)
+	s.RLock()
+	result := s.result
+	s.RUnlock()
+	if game.GridState != engine.Empty || result != nil {
+		return ehttp.NewErrorf(http.StatusForbidden, "game '%s' is finished", gameID)
+	}
+	return nil
+}
+
+// ResignReq is the request to resign a game.
+type ResignReq struct {
+	GameID string `json:"-"`
+	Token  string `json:"-"`
+}
+
+// resign ends the game on the resignation of the player holding the token.
+// The game is left as is and released, its listeners receive the end of the game.
+func (r *Runtime) resign(data *ResignReq) (*engine.Result, error) {
+	game, err := r.game(data.GameID)
+	if err != nil {
+		return nil, err
+	}
+	seat, err := r.seat(data.GameID, game, data.Token, "")
+	if err != nil {
+		return nil, err
+	}
+	s := r.session(data.GameID)
+	s.play.Lock()
+	defer s.play.Unlock()
+	if err := r.finished(data.GameID, game); err != nil {
+		return nil, err
+	}
+	result := &engine.Result{Kind: engine.ResultResign, Player: seat}
+	s.Lock()
+	s.result = result
+	s.Unlock()
+	s.publish(Event{Type: EventGameOver, Result: result})
+	game.Release()
+	return result, nil
+}
+
+// ChatReq is the request to send a chat message.
+type ChatReq struct {
+	GameID  string `json:"-"`
+	Token   string `json:"-"`
+	Message string `json:"message"`
+}
+
+// maxChatMessage is the maximum length of a chat message, in bytes.
+const maxChatMessage = 512
+
+// chat appends the message of the player holding the token to the game chat.
+func (r *Runtime) chat(data *ChatReq) (ChatMessage, error) {
+	game, err := r.game(data.GameID)
+	if err != nil {
+		return ChatMessage{}, err
+	}
+	seat, err := r.seat(data.GameID, game, data.Token, "")
+	if err != nil {
+		return ChatMessage{}, err
+	}
+	if data.Message == "" {
+		return ChatMessage{}, ehttp.NewErrorf(http.StatusBadRequest, "missing message")
+	}
+	if len(data.Message) > maxChatMessage {
+		return ChatMessage{}, ehttp.NewErrorf(http.StatusBadRequest, "message too long, %d bytes max", maxChatMessage)
+	}
+	game.RLock()
+	msg := ChatMessage{Player: seat, PlayerName: game.Players[seat], Message: data.Message, Time: time.Now()}
+	game.RUnlock()
+
+	s := r.session(data.GameID)
+	s.Lock()
+	s.chat = append(s.chat, msg)
+	s.Unlock()
+	s.publish(Event{Type: EventChat, Chat: &msg})
+	return msg, nil
+}
+
+// chatMessages returns the chat of the given game.
+func (r *Runtime) chatMessages(gameID string) ([]ChatMessage, error) {
+	if _, err := r.game(gameID); err != nil {
+		return nil, err
+	}
+	s := r.session(gameID)
+	s.RLock()
+	defer s.RUnlock()
+	return append([]ChatMessage{}, s.chat...), nil
+}
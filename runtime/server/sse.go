@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/creack/ehttp"
+	"github.com/creack/gofour/engine"
+	"github.com/julienschmidt/httprouter"
+)
+
+// sseKeepAlive is the interval of the comments sent to keep idle streams open through proxies.
+const sseKeepAlive = 30 * time.Second
+
+// gameStreamV1 is the Server-Sent Events stream of the game. Each Event of the
+// game is sent with its ID, as the SSE id field, and its type, as the SSE event
+// field. A new client first receives a snapshot of the game, bearing the ID of
+// the last event it includes. A client reconnecting with the Last-Event-ID
+// header, or the last_event_id query string, receives the events it missed
+// instead, or a new snapshot when they are no longer available.
+func (r *Runtime) gameStreamV1(w http.ResponseWriter, req *http.Request, p httprouter.Params) error {
+	gameID := p.ByName("id")
+	game, err := r.game(gameID)
+	if err != nil {
+		return err
+	}
+	lastEventID := req.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = req.URL.Query().Get("last_event_id")
+	}
+	var (
+		s      = r.session(gameID)
+		events chan Event
+		missed []Event
+		ok     bool
+	)
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			return ehttp.NewErrorf(http.StatusBadRequest, "invalid last event id '%s'", lastEventID)
+		}
+		events, missed, ok = s.resume(id)
+	}
+	// Subscribe along the snapshot so no change is missed after it.
+	var (
+		snapshot   *engine.Four
+		snapshotID uint64
+	)
+	if !ok {
+		s.play.Lock()
+		events, snapshotID = s.subscribe()
+		snapshot = game.Clone()
+		s.play.Unlock()
+	}
+	defer s.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if !ok {
+		if err := writeEvent(w, snapshotID, Event{Type: EventSnapshot, Game: snapshot}); err != nil {
+			return err
+		}
+	}
+	for _, e := range missed {
+		if err := writeEvent(w, e.ID, e); err != nil {
+			return err
+		}
+	}
+	w.(http.Flusher).Flush()
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				// Dropped for being too slow, the client reconnects and resumes.
+				return nil
+			}
+			if err := writeEvent(w, e.ID, e); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return err
+			}
+		case <-req.Context().Done():
+			return nil
+		}
+		w.(http.Flusher).Flush()
+	}
+}
+
+// writeEvent sends the event in the Server-Sent Events format with the given ID.
+func writeEvent(w http.ResponseWriter, id uint64, e Event) error {
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, e.Type, buf)
+	return err
+}
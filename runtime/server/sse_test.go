@@ -0,0 +1,180 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sseClient reads the Server-Sent Events of a game stream.
+type sseClient struct {
+	resp *http.Response
+	r    *bufio.Reader
+}
+
+// dialStream opens the stream at the given url with the given Last-Event-ID header, if any.
+func dialStream(t *testing.T, u, lastEventID string) *sseClient {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		_ = resp.Body.Close()
+		t.Fatalf("Unexpected stream response: %s %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	return &sseClient{resp: resp, r: bufio.NewReader(resp.Body)}
+}
+
+// next returns the next event of the stream along with its SSE id, skipping the comments.
+func (c *sseClient) next(t *testing.T) (uint64, Event) {
+	var (
+		id  uint64
+		typ string
+		e   Event
+	)
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Unexpected error reading the stream: %s", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && typ != "":
+			if e.Type != typ {
+				t.Fatalf("Unexpected event type.\nExpected:\t%s\nGot:\t\t%s", typ, e.Type)
+			}
+			return id, e
+		case strings.HasPrefix(line, "id: "):
+			if id, err = strconv.ParseUint(line[len("id: "):], 10, 64); err != nil {
+				t.Fatalf("Unexpected event id %q", line)
+			}
+		case strings.HasPrefix(line, "event: "):
+			typ = line[len("event: "):]
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(line[len("data: "):]), &e); err != nil {
+				t.Fatalf("Unexpected event data %q: %s", line, err)
+			}
+		}
+	}
+}
+
+// expect reads the next events and fails unless they are the moves with the given IDs.
+func (c *sseClient) expect(t *testing.T, ids ...uint64) {
+	for _, expect := range ids {
+		id, e := c.next(t)
+		if id != expect || e.ID != expect || e.Type != EventMovePlayed {
+			t.Fatalf("Unexpected event.\nExpected:\t%d %s\nGot:\t\t%d %s (id %d)", expect, EventMovePlayed, id, e.Type, e.ID)
+		}
+	}
+}
+
+// expectSnapshot reads the next event and fails unless it is a snapshot with the given ID and moves.
+func (c *sseClient) expectSnapshot(t *testing.T, expect uint64, moves int) {
+	id, e := c.next(t)
+	if id != expect || e.Type != EventSnapshot || e.Game == nil || len(e.Game.History) != moves {
+		t.Fatalf("Unexpected first event.\nExpected:\t%d %s of %d moves\nGot:\t\t%d %s", expect, EventSnapshot, moves, id, e.Type)
+	}
+}
+
+// Close closes the stream.
+func (c *sseClient) Close() error {
+	return c.resp.Body.Close()
+}
+
+// TestGameStream checks the stream starts with a snapshot, then sends every
+// event, and resumes from the last event seen without gap nor duplicate.
+func TestGameStream(t *testing.T) {
+	r := &Runtime{}
+	if err := r.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(r.Handler())
+	defer srv.Close()
+
+	gameID, _, err := r.createGame(newCreateGameReq())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tokens []string
+	for _, name := range []string{"alice", "bob"} {
+		resp, err := r.joinGame(&JoinGameReq{GameID: gameID, PlayerName: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, resp.Token)
+	}
+	moves := 0
+	play := func(n int) {
+		for ; n > 0; n-- {
+			// Along the bottom row, nobody wins.
+			if _, err := r.playMove(&PlayMoveReq{GameID: gameID, Token: tokens[moves%2], Column: moves % 7, Row: -1, Action: "drop"}); err != nil {
+				t.Fatal(err)
+			}
+			moves++
+		}
+	}
+	u := srv.URL + "/v1/games/" + gameID + "/stream"
+
+	// The snapshot bears the ID of the last event, the joins.
+	c := dialStream(t, u, "")
+	c.expectSnapshot(t, 2, 0)
+	play(4)
+	c.expect(t, 3, 4)
+	_ = c.Close()
+
+	// The events missed while disconnected are sent first, then the new ones.
+	play(2)
+	c = dialStream(t, u, "4")
+	c.expect(t, 5, 6, 7, 8)
+	play(1)
+	c.expect(t, 9)
+	_ = c.Close()
+
+	c = dialStream(t, u+"?last_event_id=7", "")
+	c.expect(t, 8, 9)
+	play(1)
+	c.expect(t, 10)
+	_ = c.Close()
+
+	// Up to date clients only get the new events.
+	c = dialStream(t, u, "10")
+	play(1)
+	c.expect(t, 11)
+	_ = c.Close()
+
+	// Unknown IDs get a new snapshot.
+	c = dialStream(t, u, "100")
+	c.expectSnapshot(t, 11, moves)
+	_ = c.Close()
+
+	// As the events no longer logged.
+	s := r.session(gameID)
+	for i := 0; i < eventLogSize; i++ {
+		s.publish(Event{Type: EventChat, Chat: &ChatMessage{Message: "spam"}})
+	}
+	c = dialStream(t, u+"?last_event_id=9", "")
+	c.expectSnapshot(t, 11+eventLogSize, moves)
+	play(1)
+	c.expect(t, 12+eventLogSize)
+	_ = c.Close()
+
+	resp, err := http.Get(u + "?last_event_id=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Unexpected status with an invalid last event id.\nExpected:\t%d\nGot:\t\t%d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
@@ -0,0 +1,344 @@
+package server
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creack/ehttp"
+	"github.com/pkg/errors"
+)
+
+// WebSocket frame opcodes.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// WebSocket close codes.
+const (
+	wsCloseNormal   = 1000
+	wsCloseProtocol = 1002
+	wsClosePolicy   = 1008
+	wsCloseTooBig   = 1009
+)
+
+const (
+	wsGUID         = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11" // Handshake key suffix, see RFC 6455.
+	wsMaxMessage   = 1 << 16                                // Maximum size of a message, in bytes.
+	wsPingInterval = 30 * time.Second                       // Interval of the pings keeping idle sockets alive.
+	wsReadTimeout  = 2 * wsPingInterval                     // Maximum wait for a frame, pongs included.
+	wsWriteTimeout = 10 * time.Second                       // Maximum wait to send a frame.
+)
+
+// Common errors.
+var (
+	ErrWebSocketClosed   = errors.New("websocket closed")
+	ErrWebSocketProtocol = errors.New("websocket protocol error")
+	ErrWebSocketTooBig   = errors.New("websocket message too big")
+)
+
+// WebSocket is a minimal RFC 6455 connection, without extensions, used by
+// the live game endpoint.
+type WebSocket struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	client bool // Client side frames are masked, server side ones are not.
+
+	mu     sync.Mutex // Serializes the writes.
+	closed bool       // Set once the close frame is sent.
+}
+
+// wsAccept returns the Sec-WebSocket-Accept value for the given key.
+func wsAccept(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// headerHas returns true if the comma separated values of the header hold the token, case insensitive.
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, elem := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(elem), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin returns true if the request has no Origin header, as sent by
+// the clients other than browsers, or if the origin is the requested host.
+// Browsers send cookies and credentials along with cross site sockets.
+func sameOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, req.Host)
+}
+
+// upgradeWebSocket checks the handshake of the request and takes over its connection.
+func upgradeWebSocket(w http.ResponseWriter, req *http.Request) (*WebSocket, error) {
+	if req.Method != "GET" {
+		return nil, ehttp.NewErrorf(http.StatusMethodNotAllowed, "websocket handshake expects GET, not %s", req.Method)
+	}
+	if !headerHas(req.Header, "Connection", "upgrade") || !headerHas(req.Header, "Upgrade", "websocket") {
+		return nil, ehttp.NewErrorf(http.StatusBadRequest, "websocket upgrade expected")
+	}
+	if !sameOrigin(req) {
+		return nil, ehttp.NewErrorf(http.StatusForbidden, "websocket origin '%s' not allowed", req.Header.Get("Origin"))
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, ehttp.NewErrorf(http.StatusUpgradeRequired, "unsupported websocket version '%s'", req.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, ehttp.NewErrorf(http.StatusBadRequest, "missing websocket key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, ehttp.NewErrorf(http.StatusInternalServerError, "websocket not supported by the connection")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, ehttp.NewErrorf(http.StatusInternalServerError, "error taking over the connection: %s", err)
+	}
+	if _, err := io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: "+wsAccept(key)+"\r\n\r\n"); err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "error sending websocket handshake")
+	}
+	return &WebSocket{conn: conn, r: brw.Reader}, nil
+}
+
+// writeFrame sends a single, final, frame.
+func (ws *WebSocket) writeFrame(op byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return ErrWebSocketClosed
+	}
+	if op == opClose {
+		ws.closed = true
+	}
+
+	if err := ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 0, 14+len(payload))
+	buf = append(buf, 0x80|op)
+	var mask byte
+	if ws.client {
+		mask = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, mask|byte(n))
+	case n <= 0xFFFF:
+		buf = append(buf, mask|126, 0, 0)
+		binary.BigEndian.PutUint16(buf[len(buf)-2:], uint16(n))
+	default:
+		buf = append(buf, mask|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(buf[len(buf)-8:], uint64(n))
+	}
+	if !ws.client {
+		buf = append(buf, payload...)
+	} else {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return errors.Wrap(err, "error generating websocket mask")
+		}
+		buf = append(buf, key[:]...)
+		for i, b := range payload {
+			buf = append(buf, b^key[i%4])
+		}
+	}
+	_, err := ws.conn.Write(buf)
+	return err
+}
+
+// readFrame reads a frame and returns its unmasked payload.
+// Fails if no frame comes within wsReadTimeout.
+func (ws *WebSocket) readFrame() (fin bool, op byte, payload []byte, err error) {
+	if err := ws.conn.SetReadDeadline(time.Now().Add(wsReadTimeout)); err != nil {
+		return false, 0, nil, err
+	}
+	var h [2]byte
+	if _, err := io.ReadFull(ws.r, h[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, op = h[0]&0x80 != 0, h[0]&0x0F
+	// No extension is negotiated, the reserved bits must be clear.
+	// Clients must mask their frames, servers must not.
+	masked := h[1]&0x80 != 0
+	if h[0]&0x70 != 0 || masked == ws.client {
+		return false, 0, nil, ErrWebSocketProtocol
+	}
+
+	n := uint64(h[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	// Control frames are small and not fragmented.
+	if op&0x8 != 0 && (n > 125 || !fin) {
+		return false, 0, nil, ErrWebSocketProtocol
+	}
+	if n > wsMaxMessage {
+		return false, 0, nil, ErrWebSocketTooBig
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(ws.r, key[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(ws.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+// ReadMessage returns the next text or binary message, reassembling the
+// fragments and answering the pings on the way. Returns io.EOF once the
+// peer closed the connection.
+func (ws *WebSocket) ReadMessage() ([]byte, error) {
+	var msg []byte
+	started := false
+	for {
+		fin, op, payload, err := ws.readFrame()
+		switch err {
+		case nil:
+		case ErrWebSocketProtocol:
+			_ = ws.closeWith(wsCloseProtocol, err.Error())
+			return nil, err
+		case ErrWebSocketTooBig:
+			_ = ws.closeWith(wsCloseTooBig, err.Error())
+			return nil, err
+		default:
+			return nil, err
+		}
+
+		switch op {
+		case opPing:
+			if err := ws.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			// Echo the status code, then hang up.
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			_ = ws.writeFrame(opClose, payload)
+			_ = ws.conn.Close()
+			return nil, io.EOF
+		case opText, opBinary:
+			if started {
+				_ = ws.closeWith(wsCloseProtocol, "unexpected data frame")
+				return nil, ErrWebSocketProtocol
+			}
+			started, msg = true, payload
+		case opContinuation:
+			if !started {
+				_ = ws.closeWith(wsCloseProtocol, "unexpected continuation frame")
+				return nil, ErrWebSocketProtocol
+			}
+			msg = append(msg, payload...)
+		default:
+			_ = ws.closeWith(wsCloseProtocol, "unknown opcode")
+			return nil, ErrWebSocketProtocol
+		}
+		if len(msg) > wsMaxMessage {
+			_ = ws.closeWith(wsCloseTooBig, ErrWebSocketTooBig.Error())
+			return nil, ErrWebSocketTooBig
+		}
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// Ping sends a ping, the peer answers with a pong.
+func (ws *WebSocket) Ping() error {
+	return ws.writeFrame(opPing, nil)
+}
+
+// WriteMessage sends a text message.
+func (ws *WebSocket) WriteMessage(msg []byte) error {
+	return ws.writeFrame(opText, msg)
+}
+
+// ReadJSON reads the next message and decodes it in v.
+func (ws *WebSocket) ReadJSON(v interface{}) error {
+	msg, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(msg, v)
+}
+
+// WriteJSON sends v as a JSON text message.
+func (ws *WebSocket) WriteJSON(v interface{}) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.WriteMessage(msg)
+}
+
+// closeWith sends a close frame with the given code and reason, then closes the connection.
+func (ws *WebSocket) closeWith(code uint16, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, code)
+	payload = append(payload, reason...)
+	err := ws.writeFrame(opClose, payload)
+	if err1 := ws.conn.Close(); err == nil || err == ErrWebSocketClosed {
+		err = err1
+	}
+	return err
+}
+
+// Close sends a normal close frame and closes the connection.
+func (ws *WebSocket) Close() error {
+	return ws.closeWith(wsCloseNormal, "")
+}
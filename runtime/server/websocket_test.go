@@ -0,0 +1,345 @@
+package server
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/creack/ehttp"
+	"github.com/creack/gofour/engine"
+	"github.com/pkg/errors"
+)
+
+// dialWebSocket opens a client connection to the given ws:// or http:// url,
+// sending the given extra headers with the handshake.
+func dialWebSocket(rawurl string, header http.Header) (*WebSocket, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid websocket url")
+	}
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		return nil, errors.Wrap(err, "error connecting to the websocket")
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "error generating websocket key")
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req := &http.Request{Method: "GET", URL: u, Host: u.Host, Header: http.Header{}}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "error sending websocket handshake")
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "error reading websocket handshake")
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		_ = conn.Close()
+		return nil, errors.Errorf("websocket handshake refused: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAccept(key) {
+		_ = conn.Close()
+		return nil, errors.New("invalid websocket handshake accept")
+	}
+	return &WebSocket{conn: conn, r: r, client: true}, nil
+}
+
+// echoServer returns a server echoing the messages of its sockets.
+// The error ending each socket is sent on the returned channel.
+func echoServer() (*httptest.Server, <-chan error) {
+	errs := make(chan error, 1)
+	srv := httptest.NewServer(ehttp.HandlerFunc(func(w http.ResponseWriter, req *http.Request) error {
+		ws, err := upgradeWebSocket(w, req)
+		if err != nil {
+			return err
+		}
+		for {
+			msg, err := ws.ReadMessage()
+			if err != nil {
+				errs <- err
+				return nil
+			}
+			if err := ws.WriteMessage(msg); err != nil {
+				errs <- err
+				return nil
+			}
+		}
+	}))
+	return srv, errs
+}
+
+// writeRaw sends the given frame header and payload, masked with a fixed key if mask is set.
+func writeRaw(t *testing.T, ws *WebSocket, h []byte, payload []byte, mask bool) {
+	buf := append([]byte(nil), h...)
+	key := []byte{1, 2, 3, 4}
+	if mask {
+		buf[1] |= 0x80
+		buf = append(buf, key...)
+	}
+	for i, b := range payload {
+		if mask {
+			b ^= key[i%4]
+		}
+		buf = append(buf, b)
+	}
+	if _, err := ws.conn.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectClose reads the next frame and fails unless it closes with the given code.
+func expectClose(t *testing.T, ws *WebSocket, code uint16) {
+	_, op, payload, err := ws.readFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != opClose || len(payload) < 2 {
+		t.Fatalf("Unexpected frame.\nExpected:\tclose\nGot:\t\top %d, %q", op, payload)
+	}
+	if got := binary.BigEndian.Uint16(payload); got != code {
+		t.Fatalf("Unexpected close code.\nExpected:\t%d\nGot:\t\t%d", code, got)
+	}
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	srv, _ := echoServer()
+	defer srv.Close()
+
+	for _, elem := range []struct {
+		name   string
+		header http.Header
+		status string // Empty when the handshake succeeds.
+	}{
+		{name: "valid"},
+		{name: "same origin", header: http.Header{"Origin": {srv.URL}}},
+		{name: "foreign origin", header: http.Header{"Origin": {"http://example.com"}}, status: "403"},
+		{name: "no upgrade", header: http.Header{"Upgrade": {"h2c"}}, status: "400"},
+		{name: "old version", header: http.Header{"Sec-Websocket-Version": {"8"}}, status: "426"},
+		{name: "no key", header: http.Header{"Sec-Websocket-Key": {""}}, status: "400"},
+	} {
+		ws, err := dialWebSocket(srv.URL, elem.header)
+		switch {
+		case elem.status == "" && err != nil:
+			t.Errorf("[%s] Unexpected error: %s", elem.name, err)
+		case elem.status == "":
+			_ = ws.Close()
+		case err == nil:
+			t.Errorf("[%s] Unexpected success, expected status %s", elem.name, elem.status)
+			_ = ws.Close()
+		case !strings.Contains(err.Error(), elem.status):
+			t.Errorf("[%s] Unexpected error.\nExpected:\tstatus %s\nGot:\t\t%s", elem.name, elem.status, err)
+		}
+	}
+
+	resp, err := http.Post(srv.URL, "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("Unexpected status.\nExpected:\t%d\nGot:\t\t%d", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+}
+
+// TestWebSocketMessages checks masked messages, fragmented or not, come back as sent.
+func TestWebSocketMessages(t *testing.T) {
+	srv, _ := echoServer()
+	defer srv.Close()
+	ws, err := dialWebSocket(srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ws.Close() }()
+
+	for _, msg := range []string{"", "hello", strings.Repeat("x", 600), strings.Repeat("y", wsMaxMessage)} {
+		if err := ws.WriteMessage([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != msg {
+			t.Fatalf("Unexpected echo of %d bytes, got %d bytes", len(msg), len(got))
+		}
+	}
+
+	// A ping in the middle of a fragmented message is answered first.
+	writeRaw(t, ws, []byte{opText, 3}, []byte("hel"), true)
+	writeRaw(t, ws, []byte{0x80 | opPing, 2}, []byte("hi"), true)
+	writeRaw(t, ws, []byte{0x80 | opContinuation, 2}, []byte("lo"), true)
+	if fin, op, payload, err := ws.readFrame(); err != nil || !fin || op != opPong || string(payload) != "hi" {
+		t.Fatalf("Unexpected answer to the ping: %t, %d, %q, %v", fin, op, payload, err)
+	}
+	if got, err := ws.ReadMessage(); err != nil || string(got) != "hello" {
+		t.Fatalf("Unexpected echo.\nExpected:\thello\nGot:\t\t%q, %v", got, err)
+	}
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	srv, errs := echoServer()
+	defer srv.Close()
+
+	for _, elem := range []struct {
+		name    string
+		h       []byte
+		payload []byte
+		mask    bool
+		code    uint16
+		err     error
+	}{
+		{name: "unmasked", h: []byte{0x80 | opText, 2}, payload: []byte("hi"), code: wsCloseProtocol, err: ErrWebSocketProtocol},
+		{name: "reserved bits", h: []byte{0xC0 | opText, 2}, payload: []byte("hi"), mask: true, code: wsCloseProtocol, err: ErrWebSocketProtocol},
+		{name: "unknown opcode", h: []byte{0x83, 0}, mask: true, code: wsCloseProtocol, err: ErrWebSocketProtocol},
+		{name: "fragmented ping", h: []byte{opPing, 0}, mask: true, code: wsCloseProtocol, err: ErrWebSocketProtocol},
+		{name: "lone continuation", h: []byte{0x80 | opContinuation, 0}, mask: true, code: wsCloseProtocol, err: ErrWebSocketProtocol},
+		{name: "too big", h: []byte{0x80 | opText, 127, 0, 0, 0, 0, 0, 1, 0, 1}, mask: true, code: wsCloseTooBig, err: ErrWebSocketTooBig},
+	} {
+		ws, err := dialWebSocket(srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeRaw(t, ws, elem.h, elem.payload, elem.mask)
+		expectClose(t, ws, elem.code)
+		if err := <-errs; err != elem.err {
+			t.Errorf("[%s] Unexpected server error.\nExpected:\t%v\nGot:\t\t%v", elem.name, elem.err, err)
+		}
+		_ = ws.conn.Close()
+	}
+
+	// Fragments adding up past the limit.
+	ws, err := dialWebSocket(srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ws.conn.Close() }()
+	half := make([]byte, wsMaxMessage/2+1)
+	h := []byte{opText, 126, 0, 0}
+	binary.BigEndian.PutUint16(h[2:], uint16(len(half)))
+	writeRaw(t, ws, h, half, true)
+	h[0] = 0x80 | opContinuation
+	writeRaw(t, ws, h, half, true)
+	expectClose(t, ws, wsCloseTooBig)
+	if err := <-errs; err != ErrWebSocketTooBig {
+		t.Fatalf("Unexpected server error.\nExpected:\t%v\nGot:\t\t%v", ErrWebSocketTooBig, err)
+	}
+}
+
+// TestWebSocketClose checks the closing handshake ends the socket on both sides.
+func TestWebSocketClose(t *testing.T) {
+	srv, errs := echoServer()
+	defer srv.Close()
+	ws, err := dialWebSocket(srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ws.conn.Close() }()
+
+	if err := ws.writeFrame(opClose, []byte{0x03, 0xE8, 'b', 'y', 'e'}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != io.EOF {
+		t.Fatalf("Unexpected server error.\nExpected:\t%v\nGot:\t\t%v", io.EOF, err)
+	}
+	expectClose(t, ws, wsCloseNormal)
+	if err := ws.WriteMessage([]byte("late")); err != ErrWebSocketClosed {
+		t.Fatalf("Unexpected error writing after close.\nExpected:\t%v\nGot:\t\t%v", ErrWebSocketClosed, err)
+	}
+}
+
+// TestGameSocket plays a move on the live game socket and checks both the
+// player and a spectator receive it.
+func TestGameSocket(t *testing.T) {
+	r := &Runtime{}
+	if err := r.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(r.Handler())
+	defer srv.Close()
+
+	data := newCreateGameReq()
+	gameID, _, err := r.createGame(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tokens []string
+	for _, name := range []string{"alice", "bob"} {
+		resp, err := r.joinGame(&JoinGameReq{GameID: gameID, PlayerName: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, resp.Token)
+	}
+
+	u := "ws" + strings.TrimPrefix(srv.URL, "http") + "/v1/games/" + gameID + "/ws"
+	if _, err := dialWebSocket(u+"?token=invalid", nil); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("Unexpected error with an invalid token: %v", err)
+	}
+	player, err := dialWebSocket(u+"?token="+tokens[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = player.Close() }()
+	spectator, err := dialWebSocket(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = spectator.Close() }()
+	for _, ws := range []*WebSocket{player, spectator} {
+		var e Event
+		if err := ws.ReadJSON(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Type != EventSnapshot || e.Game == nil {
+			t.Fatalf("Unexpected first event.\nExpected:\t%s\nGot:\t\t%s", EventSnapshot, e.Type)
+		}
+	}
+
+	if err := spectator.WriteJSON(ClientMessage{Type: "move", Column: 3, Row: -1, Action: "drop"}); err != nil {
+		t.Fatal(err)
+	}
+	var e Event
+	if err := spectator.ReadJSON(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != EventError || e.Error.Code != http.StatusUnauthorized {
+		t.Fatalf("Unexpected answer to a spectator move: %s %+v", e.Type, e.Error)
+	}
+
+	if err := player.WriteJSON(ClientMessage{Type: "move", Column: 3, Row: -1, Action: "drop"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, ws := range []*WebSocket{player, spectator} {
+		var e Event
+		if err := ws.ReadJSON(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Type != EventMovePlayed || e.Move == nil || e.Move.Player != engine.Red || e.NextPlayer != engine.Yellow {
+			t.Fatalf("Unexpected event after a move: %+v", e)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package terminal
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/creack/gofour/engine"
@@ -22,15 +23,18 @@ type Runtime struct {
 	grid *gogrid.Grid
 
 	cursorX int  // Current cursor.
+	cursorY int  // Current row, for free placement.
 	end     bool // Flag for end of game.
+	hints   bool // Flag for the threat highlights.
 }
 
 // Run starts the runtime.
 func (tf *Runtime) Run() error {
 	// First draw.
-	if err := tf.grid.RedrawAll(); err != nil {
+	if err := tf.redraw(tf.grid); err != nil {
 		return errors.Wrap(err, "error drawing grid")
 	}
+	tf.playBots(tf.grid)
 	// Start the runtime loop.
 	if err := tf.grid.HandleKeyboard(); err != nil {
 		return errors.Wrap(err, "runtime error")
@@ -38,13 +42,126 @@ func (tf *Runtime) Run() error {
 	return nil
 }
 
+// redraw redraws the grid along with the pieces already played.
+func (tf *Runtime) redraw(g *gogrid.Grid) error {
+	if err := g.RedrawAll(); err != nil {
+		return err
+	}
+	if tf.four.Wraps() {
+		drawWrap(g)
+	}
+	for i := 0; i < tf.four.Rows; i++ {
+		for j := 0; j < tf.four.Columns; j++ {
+			if s := tf.four.State(i, j); s != engine.Empty {
+				g.SetCursor(j, i)
+				fmt.Printf("%s", s)
+			}
+		}
+	}
+	if tf.end {
+		tf.showResult(g, tf.four.GridState)
+	}
+	return nil
+}
+
+// drawWrap marks the side borders of a cylinder grid, where the lines wrap around.
+func drawWrap(g *gogrid.Grid) {
+	for i := 0; i < g.Height; i++ {
+		termbox.SetCell(0, g.HeaderHeight+1+2*i, '┆', termbox.ColorCyan, termbox.ColorDefault)
+		termbox.SetCell(g.Width*2, g.HeaderHeight+1+2*i, '┆', termbox.ColorCyan, termbox.ColorDefault)
+	}
+	_ = termbox.Flush()
+}
+
 // HeaderHandler displays info in the header section of the grid.
 func (tf *Runtime) HeaderHandler(g *gogrid.Grid) {
 	if !tf.end {
 		// Display player info.
-		fmt.Printf("Player %d (%s) turn, select column (Enter or Space)\n", tf.four.CurPlayer, tf.four.CurPlayer)
+		if tf.four.FreePlacement() {
+			fmt.Printf("Player %d (%s) turn, select cell (Arrows, Enter or Space, u to undo, r to redo, h for hints)\n", tf.four.CurPlayer, tf.four.CurPlayer)
+		} else {
+			pop := ""
+			if runtime.CanPop(tf.four) {
+				pop = ", Down to pop"
+			}
+			fmt.Printf("Player %d (%s) turn, select column (Enter or Space%s, u to undo, r to redo, h for hints)\n", tf.four.CurPlayer, tf.four.CurPlayer, pop)
+		}
+		fmt.Printf("Live windows: %s", runtime.LiveWindows(tf.four))
+		if len(tf.four.Placements) != 0 {
+			fmt.Printf(" - Ranking: %s", runtime.Standings(tf.four))
+		}
+		if tf.hints {
+			tf.drawHints(g)
+		}
 		// Set cursor to proper cell.
-		g.SetCursor(tf.cursorX, 0)
+		if tf.four.FreePlacement() {
+			g.SetCursor(tf.cursorX, tf.cursorY)
+		} else {
+			g.SetCursor(tf.cursorX, 0)
+		}
+	}
+}
+
+// drawHints marks the empty cells with the threats of the position: * for the
+// current player's wins, ! for the cells to block, x for the drops handing a
+// win to an opponent and o/e, in the owner's color, for the odd/even threats.
+// Unmarked empty cells are cleared.
+func (tf *Runtime) drawHints(g *gogrid.Grid) {
+	marks := map[engine.Cell]string{}
+	t := tf.four.Threats()
+	for _, p := range tf.four.AvailablePlayers {
+		for _, c := range t.Odd[p] {
+			marks[c] = hint(p, 'o')
+		}
+		for _, c := range t.Even[p] {
+			marks[c] = hint(p, 'e')
+		}
+	}
+	for _, m := range t.Gifts {
+		marks[tf.hintCell(m)] = "\x1b[1;33mx\x1b[0m"
+	}
+	for _, m := range t.Blocks {
+		marks[tf.hintCell(m)] = "\x1b[1;31m!\x1b[0m"
+	}
+	for _, m := range t.Wins[tf.four.CurPlayer] {
+		if !m.Pop {
+			marks[tf.hintCell(m)] = "\x1b[1;32m*\x1b[0m"
+		}
+	}
+
+	for i := 0; i < tf.four.Rows; i++ {
+		for j := 0; j < tf.four.Columns; j++ {
+			if tf.four.State(i, j) != engine.Empty {
+				continue
+			}
+			g.SetCursor(j, i)
+			if mark, ok := marks[engine.Cell{Row: i, Col: j}]; ok && tf.hints {
+				fmt.Print(mark)
+			} else {
+				fmt.Print(" ")
+			}
+		}
+	}
+}
+
+// hintCell returns the cell taken by the given move.
+func (tf *Runtime) hintCell(m engine.Move) engine.Cell {
+	if tf.four.FreePlacement() {
+		return engine.Cell{Row: m.Row, Col: m.Column}
+	}
+	return engine.Cell{Row: tf.four.Landing(m.Column), Col: m.Column}
+}
+
+// hint returns the given mark in the color of the given player.
+func hint(p engine.State, mark rune) string {
+	return strings.Replace(p.String(), string(engine.PlayerUnicode), string(mark), 1)
+}
+
+func (tf *Runtime) hintsHandler(g *gogrid.Grid) {
+	tf.hints = !tf.hints
+	if !tf.hints {
+		// Clear the marks.
+		tf.drawHints(g)
 	}
 }
 
@@ -60,25 +177,124 @@ func (tf *Runtime) rightKeyHandler(g *gogrid.Grid) {
 	}
 }
 
+func (tf *Runtime) upKeyHandler(*gogrid.Grid) {
+	if tf.four.FreePlacement() && tf.cursorY > 0 {
+		tf.cursorY--
+	}
+}
+
+func (tf *Runtime) downKeyHandler(g *gogrid.Grid) {
+	if !tf.four.FreePlacement() {
+		// Pop when the variant allows it, ignore the key otherwise.
+		if runtime.CanPop(tf.four) {
+			tf.play(g, engine.Move{Player: tf.four.CurPlayer, Column: tf.cursorX, Pop: true})
+		}
+		return
+	}
+	if tf.cursorY < g.Height-1 {
+		tf.cursorY++
+	}
+}
+
 func (tf *Runtime) toggleHandler(g *gogrid.Grid) {
+	tf.play(g, engine.Move{Player: tf.four.CurPlayer, Column: tf.cursorX, Row: tf.cursorY})
+}
+
+// play plays the given move for the current player then lets the computer players move.
+func (tf *Runtime) play(g *gogrid.Grid, m engine.Move) {
 	if tf.end {
 		return
 	}
 
 	g.ClearHeader()
-	curPlayer := tf.four.CurPlayer
-	ret, err := tf.four.PlayerMove(tf.four.CurPlayer, tf.cursorX)
+	ret, _, err := tf.four.Play(m)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		g.SetCursor(0, 0)
 		return
 	}
+	tf.show(g, m, ret)
+	tf.playBots(g)
+}
+
+func (tf *Runtime) undoHandler(g *gogrid.Grid) {
+	lines := tf.four.WinningLines
+	moves, err := runtime.TakeBack(tf.four, runtime.Bots)
+	if err != nil {
+		return
+	}
+	g.ClearHeader()
+	for _, m := range moves {
+		tf.drawColumn(g, m.Column)
+		tf.cursorX, tf.cursorY = m.Column, m.Row
+	}
+	tf.drawLines(g, lines, false)
+	tf.end = false
+	tf.playBots(g)
+}
+
+func (tf *Runtime) redoHandler(g *gogrid.Grid) {
+	if tf.end {
+		return
+	}
+	m, _, err := tf.four.Redo()
+	if err != nil {
+		return
+	}
+	g.ClearHeader()
+	tf.show(g, m, m.GridState)
+	tf.playBots(g)
+}
+
+// playBots lets the computer players move until it is a human's turn or the game is over.
+func (tf *Runtime) playBots(g *gogrid.Grid) {
+	for !tf.end {
+		bot, ok := runtime.Bots[tf.four.CurPlayer]
+		if !ok {
+			return
+		}
+		g.ClearHeader()
+		fmt.Printf("Player %d (%s) thinking...\n", tf.four.CurPlayer, tf.four.CurPlayer)
+		m, err := bot.Move(tf.four)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return
+		}
+		ret, _, err := tf.four.Play(m)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return
+		}
+		g.ClearHeader()
+		tf.show(g, m, ret)
+	}
+}
+
+// show displays the given move, just played, and the end of game message if needed.
+func (tf *Runtime) show(g *gogrid.Grid, m engine.Move, ret engine.State) {
+	tf.cursorX, tf.cursorY = m.Column, m.Row
+	switch {
+	case m.Pop:
+		tf.drawColumn(g, tf.cursorX)
+	case tf.four.FreePlacement():
+		g.SetCursor(m.Column, m.Row)
+		fmt.Printf("%s", m.Player)
+	default:
+		tf.drop(g, m.Player)
+	}
+	if ret != engine.Empty {
+		tf.showResult(g, ret)
+		tf.end = true
+	}
+}
 
+// drop animates the piece of the given player falling in the cursor's column.
+func (tf *Runtime) drop(g *gogrid.Grid, player engine.State) {
 	// Make it fall as long as we are empty.
 	j := 0
 	for ; j < tf.four.ColumnCount(tf.cursorX); j++ {
 		g.SetCursor(tf.cursorX, j)
-		fmt.Printf("%s", curPlayer)
+		fmt.Printf("%s", player)
 		g.SetCursor(tf.cursorX, 0)
 
 		time.Sleep(50 * time.Millisecond)
@@ -87,24 +303,60 @@ func (tf *Runtime) toggleHandler(g *gogrid.Grid) {
 		fmt.Print(" ")
 	}
 	g.SetCursor(tf.cursorX, j)
-	fmt.Printf("%s", curPlayer)
+	fmt.Printf("%s", player)
+}
 
-	if ret != engine.Empty {
-		g.ClearHeader()
-		if ret == engine.Stale {
-			fmt.Print("\n Stale, nobody wins! (ESC to exit)")
+// drawColumn redraws the pieces of the given column.
+func (tf *Runtime) drawColumn(g *gogrid.Grid, col int) {
+	for i := 0; i < tf.four.Rows; i++ {
+		g.SetCursor(col, i)
+		if s := tf.four.State(i, col); s != engine.Empty {
+			fmt.Printf("%s", s)
 		} else {
-			fmt.Printf("\n Player %d (%s) won! (ESC to exit)", ret, ret)
+			fmt.Print(" ")
 		}
-		g.SetCursor(0, 0)
-		tf.end = true
-		return
 	}
 }
 
+// drawLines redraws the cells of the given lines, highlighted or not.
+func (tf *Runtime) drawLines(g *gogrid.Grid, lines []engine.Line, highlight bool) {
+	for _, l := range lines {
+		for _, c := range l {
+			g.SetCursor(c.Col, c.Row)
+			switch s := tf.four.State(c.Row, c.Col); {
+			case s == engine.Empty:
+				fmt.Print(" ")
+			case highlight:
+				fmt.Print(s.Highlight())
+			default:
+				fmt.Printf("%s", s)
+			}
+		}
+	}
+}
+
+// showResult displays the end of game message along with the winning lines.
+func (tf *Runtime) showResult(g *gogrid.Grid, ret engine.State) {
+	if tf.hints {
+		// No threats left, clear the marks.
+		tf.drawHints(g)
+	}
+	tf.drawLines(g, tf.four.WinningLines, true)
+	g.ClearHeader()
+	if ret == engine.Stale {
+		fmt.Print("\n Stale, nobody wins! (u to undo, ESC to exit)")
+	} else if tf.four.Ranking {
+		fmt.Printf("\n Final ranking: %s (u to undo, ESC to exit)", runtime.Standings(tf.four))
+	} else {
+		fmt.Printf("\n %s won! (u to undo, ESC to exit)", runtime.Winner(tf.four))
+	}
+	g.SetCursor(0, 0)
+}
+
 // Init initialize the termcap grid.
 func (tf *Runtime) Init(four *engine.Four) error {
 	tf.four = four
+	tf.end = four.GridState != engine.Empty
 
 	// Initialize new termbox grid.
 	g, err := gogrid.NewGrid(tf.four.Rows, tf.four.Columns)
@@ -124,8 +376,15 @@ func (tf *Runtime) Init(four *engine.Four) error {
 	g.RegisterKeyHandler(termbox.KeyCtrlF, tf.rightKeyHandler)
 	g.RegisterKeyHandler(termbox.KeySpace, tf.toggleHandler)
 	g.RegisterKeyHandler(termbox.KeyEnter, tf.toggleHandler)
+	g.RegisterKeyHandler(termbox.KeyArrowUp, tf.upKeyHandler)
+	g.RegisterKeyHandler(termbox.KeyCtrlP, tf.upKeyHandler)
+	g.RegisterKeyHandler(termbox.KeyArrowDown, tf.downKeyHandler)
+	g.RegisterKeyHandler(termbox.KeyCtrlN, tf.downKeyHandler)
+	g.RegisterKeyHandler('u', tf.undoHandler)
+	g.RegisterKeyHandler('r', tf.redoHandler)
+	g.RegisterKeyHandler('h', tf.hintsHandler)
 	g.RegisterKeyHandler('q', func(g *gogrid.Grid) { _ = g.Close() })
-	g.RegisterKeyHandler(termbox.KeyCtrlL, func(g *gogrid.Grid) { _ = g.RedrawAll() })
+	g.RegisterKeyHandler(termbox.KeyCtrlL, func(g *gogrid.Grid) { _ = tf.redraw(g) })
 
 	return nil
 }
@@ -5,6 +5,8 @@ import (
 	"io"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"text/tabwriter"
 
@@ -18,13 +20,25 @@ func init() {
 }
 
 // Dump displays the state of the grid on the given writer.
+// The winning lines, if any, are highlighted.
 func Dump(w io.Writer, f *engine.Four) {
 	fmt.Fprintln(w)
 
+	won := map[engine.Cell]bool{}
+	for _, l := range f.WinningLines {
+		for _, c := range l {
+			won[c] = true
+		}
+	}
+
 	tabW := tabwriter.NewWriter(w, 4, 4, 4, ' ', 0)
 	for i := 0; i < f.Columns; i++ {
 		fmt.Fprintf(tabW, "\x1b[1;37m%d\x1b[0m\t", i+1)
 	}
+	// Mark the wrap edge of a cylinder grid.
+	if f.Wraps() {
+		fmt.Fprint(tabW, "\x1b[1;36m↻\x1b[0m")
+	}
 	fmt.Fprintln(tabW)
 	for i := 0; i < f.Columns; i++ {
 		fmt.Fprint(tabW, "\x1b[1;37m---\x1b[0m\t")
@@ -32,7 +46,18 @@ func Dump(w io.Writer, f *engine.Four) {
 	fmt.Fprintln(tabW)
 	for i := 0; i < f.Rows; i++ {
 		for j := 0; j < f.Columns; j++ {
-			fmt.Fprintf(tabW, "%s\t", f.State(i, j))
+			if won[engine.Cell{Row: i, Col: j}] {
+				fmt.Fprintf(tabW, "%s\t", f.State(i, j).Highlight())
+			} else {
+				fmt.Fprintf(tabW, "%s\t", f.State(i, j))
+			}
+		}
+		if f.Wraps() {
+			fmt.Fprint(tabW, "\x1b[1;36m┆\x1b[0m\t")
+		}
+		// Number the rows when the moves need them.
+		if f.FreePlacement() {
+			fmt.Fprintf(tabW, "\x1b[1;37m%d\x1b[0m", i+1)
 		}
 		fmt.Fprintf(tabW, "\n")
 	}
@@ -40,6 +65,56 @@ func Dump(w io.Writer, f *engine.Four) {
 	fmt.Fprintln(w)
 }
 
+// formatMove formats a move as typed by the players, e.g. "4", "p4" or "4,2".
+func formatMove(f *engine.Four, m engine.Move) string {
+	input := strconv.Itoa(m.Column + 1)
+	if m.Pop {
+		input = "p" + input
+	}
+	if f.FreePlacement() {
+		input += "," + strconv.Itoa(m.Row+1)
+	}
+	return input
+}
+
+// formatMoves formats a list of moves, space separated.
+func formatMoves(f *engine.Four, moves []engine.Move) string {
+	elems := make([]string, 0, len(moves))
+	for _, m := range moves {
+		elems = append(elems, formatMove(f, m))
+	}
+	return strings.Join(elems, " ")
+}
+
+// formatCells formats a list of cells as 1 indexed column,row, the rows
+// counted from the top as for the moves.
+func formatCells(cells []engine.Cell) string {
+	elems := make([]string, 0, len(cells))
+	for _, c := range cells {
+		elems = append(elems, fmt.Sprintf("%d,%d", c.Col+1, c.Row+1))
+	}
+	return strings.Join(elems, " ")
+}
+
+// DumpThreats displays the threats of the current position on the given writer.
+func DumpThreats(w io.Writer, f *engine.Four) {
+	t := f.Threats()
+	for _, p := range f.AvailablePlayers {
+		if moves, ok := t.Wins[p]; ok {
+			fmt.Fprintf(w, "Winning moves for %d (%s): %s\n", p, p, formatMoves(f, moves))
+		}
+	}
+	fmt.Fprintf(w, "Must block: %s\n", formatMoves(f, t.Blocks))
+	if !f.FreePlacement() {
+		fmt.Fprintf(w, "Avoid: %s\n", formatMoves(f, t.Gifts))
+	}
+	if t.Odd != nil {
+		for _, p := range f.AvailablePlayers {
+			fmt.Fprintf(w, "Threats for %d (%s) on odd rows, counted from the bottom: %s; on even rows: %s\n", p, p, formatCells(t.Odd[p]), formatCells(t.Even[p]))
+		}
+	}
+}
+
 // Runtime is a basic text based client for connect four.
 type Runtime struct {
 	four     *engine.Four
@@ -60,7 +135,7 @@ func (r *Runtime) Init(four *engine.Four) error {
 	}()
 
 	// Watch for signals so we are not stuck in the game forever.
-	ch := make(chan os.Signal)
+	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 	go func() {
 		<-ch
@@ -75,7 +150,7 @@ func (r *Runtime) Init(four *engine.Four) error {
 
 // Run starts the game loop.
 func (r *Runtime) Run() error {
-	for i := 0; ; i++ {
+	for i := 0; r.four.GridState == engine.Empty; i++ {
 	start:
 		select {
 		case <-r.stopChan:
@@ -83,40 +158,95 @@ func (r *Runtime) Run() error {
 		default:
 		}
 		Dump(os.Stdout, r.four)
-		fmt.Printf("Player %d (%s) turn, select column:\n", r.four.CurPlayer, r.four.CurPlayer)
+		if len(r.four.Placements) != 0 {
+			fmt.Printf("Ranking: %s\n", runtime.Standings(r.four))
+		}
+		fmt.Printf("Live windows: %s\n", runtime.LiveWindows(r.four))
+		if r.four.FreePlacement() {
+			fmt.Printf("Player %d (%s) turn, select column,row (or undo, redo, threats):\n", r.four.CurPlayer, r.four.CurPlayer)
+		} else {
+			pop := ""
+			if runtime.CanPop(r.four) {
+				pop = ", p<column> to pop"
+			}
+			fmt.Printf("Player %d (%s) turn, select column (or undo, redo, threats%s):\n", r.four.CurPlayer, r.four.CurPlayer, pop)
+		}
 
-		var x int
-		if _, err := fmt.Fscanf(r.r, "%d", &x); err != nil {
+		var input string
+		if bot, ok := runtime.Bots[r.four.CurPlayer]; ok {
+			m, err := bot.Move(r.four)
+			if err != nil {
+				return err
+			}
+			input = formatMove(r.four, m)
+			fmt.Println(input)
+		} else if _, err := fmt.Fscan(r.r, &input); err != nil {
 			if err == io.EOF {
 				break
 			}
 			// Ignore other errors.
 		}
-		x-- // Back to 0 index.
 
-		if x < 0 {
-			fmt.Fprint(os.Stderr, "invalid columns number\n")
+		var (
+			ret engine.State
+			err error
+		)
+		switch input {
+		case "threats":
+			DumpThreats(os.Stdout, r.four)
 			goto start
-		}
+		case "undo":
+			if _, err := runtime.TakeBack(r.four, runtime.Bots); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+			}
+			goto start
+		case "redo":
+			var m engine.Move
+			m, _, err = r.four.Redo()
+			ret = m.GridState
+		default:
+			// Free moves are typed column,row, or column.row as in the game notation.
+			fields := strings.SplitN(strings.Replace(strings.TrimPrefix(input, "p"), ".", ",", 1), ",", 2)
+			x, _ := strconv.Atoi(fields[0])
+			x-- // Back to 0 index.
 
-		ret, err := r.four.PlayerMove(r.four.CurPlayer, x)
+			if x < 0 {
+				fmt.Fprint(os.Stderr, "invalid columns number\n")
+				goto start
+			}
+			y := 0
+			if r.four.FreePlacement() {
+				if len(fields) == 2 {
+					y, _ = strconv.Atoi(fields[1])
+				}
+				if y--; y < 0 {
+					fmt.Fprint(os.Stderr, "invalid row number\n")
+					goto start
+				}
+			}
+			ret, _, err = r.four.Play(engine.Move{Player: r.four.CurPlayer, Column: x, Row: y, Pop: strings.HasPrefix(input, "p")})
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", err)
-			if err := errors.Cause(err); err == engine.ErrInvalidMove {
+			if err := errors.Cause(err); err == engine.ErrInvalidMove || err == engine.ErrNoMove || err == engine.ErrNoPopOut {
 				goto start
 			}
 			return err
 		}
 		if ret != engine.Empty {
-			Dump(os.Stdout, r.four)
-			if ret == engine.Stale {
-				fmt.Print("Stale, nobody wins!\n")
-			} else {
-				fmt.Printf("Player %d (%s) won!\n", ret, ret)
-			}
 			break
 		}
 	}
+	if ret := r.four.GridState; ret != engine.Empty {
+		Dump(os.Stdout, r.four)
+		if ret == engine.Stale {
+			fmt.Print("Stale, nobody wins!\n")
+		} else if r.four.Ranking {
+			fmt.Printf("Final ranking: %s\n", runtime.Standings(r.four))
+		} else {
+			fmt.Printf("%s won!\n", runtime.Winner(r.four))
+		}
+	}
 	return nil
 }
 